@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// addressItem is a top level entry of an address list, either plain mailboxes
+// or a group with its member list
+type addressItem struct {
+	text    string
+	group   bool
+	name    string
+	members string
+}
+
+// addressParser decodes encoded-words in display names with headerWordDecoder
+var addressParser = &mail.AddressParser{WordDecoder: headerWordDecoder}
+
+// parseAddressList parses an address header value, including RFC 5322 groups
+// ("Team: a@example.com, b@example.com;") and malformed addresses that
+// net/mail rejects
+func parseAddressList(value string) []*Address {
+	addresses := make([]*Address, 0)
+
+	for _, item := range splitAddressItems(value) {
+		if item.group {
+			addresses = append(addresses, &Address{
+				Name:    decodeAddressName(item.name),
+				IsGroup: true,
+				Group:   parseMailboxList(item.members),
+			})
+			continue
+		}
+		addresses = append(addresses, parseMailboxList(item.text)...)
+	}
+
+	return addresses
+}
+
+// splitAddressItems splits an address list into groups and the mailbox lists
+// between them, ignoring separators in quoted strings, comments, angle brackets
+// and domain literals
+func splitAddressItems(value string) []*addressItem {
+	items := make([]*addressItem, 0)
+	var current *addressItem
+
+	inQuotes := false
+	comment := 0
+	angle := false
+	literal := false
+	start := 0
+	lastComma := -1
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && (inQuotes || comment > 0):
+			i++
+		case c == '"' && comment == 0:
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '(':
+			comment++
+		case c == ')' && comment > 0:
+			comment--
+		case comment > 0:
+		case c == '<':
+			angle = true
+		case c == '>':
+			angle = false
+		case angle:
+		case c == '[':
+			literal = true
+		case c == ']':
+			literal = false
+		case literal:
+		case c == ',' && current == nil:
+			lastComma = i
+		case c == ':' && current == nil:
+			// Mailboxes before the group name are a separate list
+			if lastComma >= start {
+				items = append(items, &addressItem{text: value[start:lastComma]})
+				start = lastComma + 1
+			}
+			current = &addressItem{group: true, name: value[start:i]}
+			start = i + 1
+		case c == ';' && current != nil:
+			current.members = value[start:i]
+			items = append(items, current)
+			current = nil
+			start = i + 1
+		}
+	}
+
+	rest := strings.TrimSpace(value[start:])
+	if current != nil {
+		// Unterminated group, the remaining text are its members
+		current.members = rest
+		items = append(items, current)
+	} else if rest = strings.Trim(rest, ", "); rest != "" {
+		items = append(items, &addressItem{text: rest})
+	}
+
+	return items
+}
+
+// parseMailboxList parses a comma separated list of mailboxes. Entries that
+// net/mail cannot parse are handled by a tolerant fallback instead of dropping
+// the whole list
+func parseMailboxList(value string) []*Address {
+	addresses := make([]*Address, 0)
+	if strings.TrimSpace(value) == "" {
+		return addresses
+	}
+
+	if list, err := addressParser.ParseList(value); err == nil {
+		for _, addr := range list {
+			addresses = append(addresses, &Address{Name: addr.Name, Address: addr.Address})
+		}
+		return addresses
+	}
+
+	for _, entry := range splitMailboxes(value) {
+		if addr, err := addressParser.Parse(entry); err == nil {
+			addresses = append(addresses, &Address{Name: addr.Name, Address: addr.Address})
+		} else if addr := parseMalformedAddress(entry); addr != nil {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	return addresses
+}
+
+// splitMailboxes splits a mailbox list on commas outside quotes, comments, angle
+// brackets and domain literals
+func splitMailboxes(value string) []string {
+	entries := make([]string, 0)
+	inQuotes := false
+	comment := 0
+	angle := false
+	literal := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && (inQuotes || comment > 0):
+			i++
+		case c == '"' && comment == 0:
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '(':
+			comment++
+		case c == ')' && comment > 0:
+			comment--
+		case comment > 0:
+		case c == '<':
+			angle = true
+		case c == '>':
+			angle = false
+		case c == '[':
+			literal = true
+		case c == ']':
+			literal = false
+		case c == ',' && !angle && !literal:
+			entries = append(entries, value[start:i])
+			start = i + 1
+		}
+	}
+	entries = append(entries, value[start:])
+
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// parseMalformedAddress extracts an address from an entry net/mail rejects,
+// e.g. unquoted special characters in the name or a missing domain
+func parseMalformedAddress(entry string) *Address {
+	if open := strings.LastIndex(entry, "<"); open >= 0 {
+		end := strings.Index(entry[open:], ">")
+		if end < 0 {
+			end = len(entry) - open
+		}
+		address := strings.TrimSpace(entry[open+1 : open+end])
+		if address == "" {
+			return nil
+		}
+		return &Address{
+			Name:    decodeAddressName(entry[:open]),
+			Address: address,
+		}
+	}
+
+	// Without angle brackets use the token that looks like an address
+	for _, token := range strings.Fields(entry) {
+		if strings.Contains(token, "@") {
+			return &Address{Address: strings.Trim(token, `"'()<>,;`)}
+		}
+	}
+
+	return nil
+}
+
+// decodeAddressName unquotes a display or group name and decodes encoded-words
+func decodeAddressName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		name = unquoteParam(name)
+	}
+
+	if decoded, err := headerWordDecoder.DecodeHeader(name); err == nil {
+		return decoded
+	}
+	return name
+}
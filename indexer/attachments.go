@@ -0,0 +1,159 @@
+package indexer
+
+import (
+	"strings"
+)
+
+// Attachment represents a decoded attachment part ready to be stored
+type Attachment struct {
+	ContentType      string    `json:"contentType"`
+	Filename         string    `json:"filename,omitempty"`
+	ContentID        string    `json:"contentId,omitempty"`
+	Disposition      string    `json:"disposition,omitempty"`
+	Related          bool      `json:"related,omitempty"`   // Part of a multipart/related referenced by Content-ID, e.g. an inline image
+	TransferEncoding string    `json:"transferEncoding"`    // Original encoding, needed to rebuild the RFC822 source
+	EncodedSize      int       `json:"encodedSize"`         // Size of the part as found in the message
+	DecodedSize      int       `json:"decodedSize"`         // Size of Content
+	Container        string    `json:"container,omitempty"` // Content type of the part this attachment was extracted from, e.g. application/ms-tnef
+	Content          []byte    `json:"-"`
+	Node             *MIMENode `json:"-"` // The part, or the container part for extracted attachments
+}
+
+// GetAttachments collects all attachment parts of a MIME tree with their
+// transfer encoding reverted
+func GetAttachments(tree *MIMENode) []*Attachment {
+	attachments := make([]*Attachment, 0)
+	collectAttachments(tree, false, &attachments)
+	return attachments
+}
+
+// ContentIDMap maps Content-IDs to attachments, so clients can resolve cid:
+// URLs of the HTML body themselves. The first part wins for duplicate IDs
+func ContentIDMap(attachments []*Attachment) map[string]*Attachment {
+	contentIDs := make(map[string]*Attachment)
+	for _, attachment := range attachments {
+		if attachment.ContentID == "" {
+			continue
+		}
+		if _, exists := contentIDs[attachment.ContentID]; !exists {
+			contentIDs[attachment.ContentID] = attachment
+		}
+	}
+	return contentIDs
+}
+
+// collectAttachments walks the tree and appends attachment nodes to the list,
+// related is set for the children of a multipart/related
+func collectAttachments(node *MIMENode, related bool, attachments *[]*Attachment) {
+	if node == nil {
+		return
+	}
+
+	contentType := contentTypeOf(node)
+	if contentType.Type == "multipart" {
+		for _, child := range node.ChildNodes {
+			collectAttachments(child, strings.EqualFold(contentType.Subtype, "related"), attachments)
+		}
+		return
+	}
+
+	if !isAttachmentPart(node, contentType) {
+		return
+	}
+
+	attachment := newAttachment(node, contentType)
+	// Parts referenced from the HTML body are not shown as real attachments
+	attachment.Related = related && attachment.ContentID != ""
+	*attachments = append(*attachments, attachment)
+
+	// Outlook wraps the real attachments into winmail.dat. The container is
+	// kept as it is part of the message source, the files follow it
+	if isTNEF(contentType) {
+		*attachments = append(*attachments, extractTNEFAttachments(attachment)...)
+	}
+}
+
+// isTNEF checks if a content type describes a TNEF (winmail.dat) part
+func isTNEF(contentType *ValueParams) bool {
+	return contentType.Type == "application" &&
+		(strings.EqualFold(contentType.Subtype, "ms-tnef") || strings.EqualFold(contentType.Subtype, "vnd.ms-tnef"))
+}
+
+// extractTNEFAttachments decodes a TNEF attachment and returns the files embedded in it.
+// The files are not part of the message source, so their content is stored as is
+func extractTNEFAttachments(container *Attachment) []*Attachment {
+	message, err := DecodeTNEF(container.Content)
+	if err != nil {
+		return nil
+	}
+
+	attachments := make([]*Attachment, 0, len(message.Attachments))
+	for _, embedded := range message.Attachments {
+		contentType := embedded.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		attachments = append(attachments, &Attachment{
+			ContentType:      contentType,
+			Filename:         embedded.Filename,
+			ContentID:        embedded.ContentID,
+			Disposition:      "attachment",
+			TransferEncoding: "binary",
+			EncodedSize:      len(embedded.Data),
+			DecodedSize:      len(embedded.Data),
+			Container:        container.ContentType,
+			Content:          embedded.Data,
+			Node:             container.Node,
+		})
+	}
+
+	return attachments
+}
+
+// isAttachmentPart checks if a non-multipart node should be stored as an attachment
+// instead of being treated as message text
+func isAttachmentPart(node *MIMENode, contentType *ValueParams) bool {
+	if isAttachment(node) {
+		return true
+	}
+
+	if contentType.Type == "text" {
+		return !strings.EqualFold(contentType.Subtype, "plain") && !strings.EqualFold(contentType.Subtype, "html")
+	}
+
+	return true
+}
+
+// newAttachment creates attachment metadata for a node and decodes its content
+func newAttachment(node *MIMENode, contentType *ValueParams) *Attachment {
+	attachment := &Attachment{
+		ContentType:      strings.ToLower(contentType.Type + "/" + contentType.Subtype),
+		TransferEncoding: transferEncodingOf(node),
+		EncodedSize:      len(node.Body),
+		Node:             node,
+	}
+
+	if disposition, ok := node.ParsedHeader["content-disposition"].(*ValueParams); ok {
+		attachment.Disposition = strings.ToLower(disposition.Value)
+		attachment.Filename = disposition.Params["filename"]
+	}
+	if attachment.Filename == "" {
+		attachment.Filename = contentType.Params["name"]
+	}
+
+	if cid, ok := node.ParsedHeader["content-id"].(string); ok {
+		attachment.ContentID = strings.Trim(strings.TrimSpace(cid), "<>")
+	}
+
+	// Keep the raw body if it can not be decoded, TransferEncoding still
+	// describes what the stored content is
+	content, err := DecodeBody(node)
+	if err != nil {
+		content = node.Body
+	}
+	attachment.Content = content
+	attachment.DecodedSize = len(content)
+
+	return attachment
+}
@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestGetAttachmentsDecodesContent(t *testing.T) {
+	email := `From: sender@example.com
+To: recipient@example.com
+Subject: Attachments
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: multipart/related; boundary="related"
+
+--related
+Content-Type: text/html; charset=utf-8
+
+<html><body><img src="cid:logo@example.com"></body></html>
+
+--related
+Content-Type: image/png; name="logo.png"
+Content-ID: <logo@example.com>
+Content-Disposition: inline
+Content-Transfer-Encoding: base64
+
+iVBORw0KGgo=
+
+--related--
+
+--mixed
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+Content-Transfer-Encoding: quoted-printable
+
+Caf=C3=A9 notes
+
+--mixed--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	attachments := GetAttachments(tree)
+	if len(attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(attachments))
+	}
+
+	image := attachments[0]
+	if image.ContentType != "image/png" || image.Filename != "logo.png" {
+		t.Errorf("Expected image/png logo.png, got %s %s", image.ContentType, image.Filename)
+	}
+	if image.ContentID != "logo@example.com" || image.Disposition != "inline" || !image.Related {
+		t.Errorf("Expected related inline logo@example.com, got %s %s (related=%t)", image.Disposition, image.ContentID, image.Related)
+	}
+	if string(image.Content) != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("Expected decoded PNG signature, got %q", image.Content)
+	}
+	if image.TransferEncoding != "base64" || image.EncodedSize != 14 || image.DecodedSize != 8 {
+		t.Errorf("Unexpected sizes: encoding=%s encoded=%d decoded=%d", image.TransferEncoding, image.EncodedSize, image.DecodedSize)
+	}
+
+	notes := attachments[1]
+	if notes.Filename != "notes.txt" || notes.Disposition != "attachment" || notes.Related {
+		t.Errorf("Expected attachment notes.txt, got %s %s (related=%t)", notes.Disposition, notes.Filename, notes.Related)
+	}
+	if string(notes.Content) != "Café notes\r\n" {
+		t.Errorf("Expected decoded text, got %q", notes.Content)
+	}
+}
+
+func TestContentIDMap(t *testing.T) {
+	attachments := []*Attachment{
+		{Filename: "logo.png", ContentID: "logo@example.com", Related: true},
+		{Filename: "report.pdf"},
+		{Filename: "duplicate.png", ContentID: "logo@example.com"},
+	}
+
+	contentIDs := ContentIDMap(attachments)
+	if len(contentIDs) != 1 {
+		t.Fatalf("Expected 1 Content-ID, got %d", len(contentIDs))
+	}
+	if attachment := contentIDs["logo@example.com"]; attachment == nil || attachment.Filename != "logo.png" {
+		t.Errorf("Expected logo.png for logo@example.com, got %+v", attachment)
+	}
+}
+
+func TestGetAttachmentsKeepsUndecodableContent(t *testing.T) {
+	email := `From: sender@example.com
+Subject: Broken
+Content-Type: application/octet-stream
+Content-Transfer-Encoding: base64
+
+not*base64`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	attachments := GetAttachments(tree)
+	if len(attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(attachments))
+	}
+	if string(attachments[0].Content) != "not*base64" {
+		t.Errorf("Expected raw content to be kept, got %q", attachments[0].Content)
+	}
+}
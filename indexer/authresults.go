@@ -0,0 +1,334 @@
+package indexer
+
+import (
+	"strings"
+)
+
+// AuthenticationResults holds the sender authentication verdicts recorded in
+// Authentication-Results, Received-SPF and DKIM-Signature headers
+type AuthenticationResults struct {
+	AuthServID     string           `json:"authServId,omitempty"`
+	SPF            *AuthResult      `json:"spf,omitempty"`
+	DKIM           []*AuthResult    `json:"dkim,omitempty"`
+	DMARC          *AuthResult      `json:"dmarc,omitempty"`
+	ARC            *AuthResult      `json:"arc,omitempty"`
+	DKIMSignatures []*DKIMSignature `json:"dkimSignatures,omitempty"`
+	Verified       bool             `json:"verified"` // Trusted DMARC pass or DKIM pass aligned with From
+}
+
+// AuthResult is a single method result, e.g. spf=pass smtp.mailfrom=example.com
+type AuthResult struct {
+	Result     string            `json:"result"` // pass, fail, softfail, neutral, none, temperror, permerror
+	Reason     string            `json:"reason,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"` // e.g. smtp.mailfrom, header.d, header.i
+}
+
+// DKIMSignature contains the identifying tags of a DKIM-Signature header
+type DKIMSignature struct {
+	Domain    string   `json:"domain"`
+	Selector  string   `json:"selector"`
+	Algorithm string   `json:"algorithm,omitempty"`
+	Headers   []string `json:"headers,omitempty"`
+}
+
+// GetAuthenticationResults parses the authentication headers of a message.
+// Authentication-Results and Received-SPF headers can be added by anyone on the
+// path, so only the topmost header from the given authserv-id is used. An empty
+// authServID reports the topmost headers for display, but the message is never
+// Verified.
+// Returns nil if the message has no authentication headers
+func GetAuthenticationResults(tree *MIMENode, authServID string) *AuthenticationResults {
+	if tree == nil {
+		return nil
+	}
+
+	results := &AuthenticationResults{}
+	found := false
+
+	for _, value := range headerValues(tree, "authentication-results") {
+		id, methods := parseAuthenticationResults(value)
+		if authServID != "" && !strings.EqualFold(id, authServID) {
+			continue
+		}
+
+		found = true
+		results.AuthServID = id
+		for _, method := range methods {
+			switch method.name {
+			case "spf":
+				results.SPF = method.result
+			case "dkim":
+				results.DKIM = append(results.DKIM, method.result)
+			case "dmarc":
+				results.DMARC = method.result
+			case "arc":
+				results.ARC = method.result
+			}
+		}
+
+		// Lower headers are older and could have been added by the sender
+		break
+	}
+
+	// Received-SPF is only used if no Authentication-Results header carried an SPF result
+	if results.SPF == nil {
+		for _, value := range headerValues(tree, "received-spf") {
+			if authServID != "" && !isReceivedSPFFrom(value, authServID) {
+				continue
+			}
+			results.SPF = parseReceivedSPF(value)
+			found = true
+			break
+		}
+	}
+
+	for _, value := range headerValues(tree, "dkim-signature") {
+		if signature := parseDKIMSignature(value); signature != nil {
+			results.DKIMSignatures = append(results.DKIMSignatures, signature)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	// Results of an unknown server could have been written by the sender
+	results.Verified = authServID != "" && strings.EqualFold(results.AuthServID, authServID) &&
+		isAuthenticated(results, fromDomain(tree))
+	return results
+}
+
+// authMethod is a parsed resinfo entry of an Authentication-Results header
+type authMethod struct {
+	name   string
+	result *AuthResult
+}
+
+// headerValues returns all values of a header in message order
+func headerValues(node *MIMENode, key string) []string {
+	switch value := node.ParsedHeader[key].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	}
+	return nil
+}
+
+// parseAuthenticationResults parses an Authentication-Results header (RFC 8601)
+// into its authserv-id and method results
+func parseAuthenticationResults(value string) (string, []*authMethod) {
+	statements := splitParams(stripHeaderComments(value))
+
+	authServID := ""
+	if fields := strings.Fields(statements[0]); len(fields) > 0 {
+		authServID = fields[0]
+	}
+
+	methods := make([]*authMethod, 0, len(statements)-1)
+	for _, statement := range statements[1:] {
+		tokens := quotedFields(statement)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(tokens[0], "=", 2)
+		if len(kv) != 2 {
+			continue // "none", no authentication was performed
+		}
+
+		// The method may carry a version, e.g. dkim/1=pass
+		name := strings.ToLower(strings.SplitN(kv[0], "/", 2)[0])
+		result := &AuthResult{
+			Result:     strings.ToLower(kv[1]),
+			Properties: make(map[string]string),
+		}
+
+		for _, token := range tokens[1:] {
+			kv := strings.SplitN(token, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key := strings.ToLower(kv[0])
+			if key == "reason" {
+				result.Reason = unquoteParam(kv[1])
+			} else {
+				result.Properties[key] = unquoteParam(kv[1])
+			}
+		}
+
+		methods = append(methods, &authMethod{name: name, result: result})
+	}
+
+	return authServID, methods
+}
+
+// parseReceivedSPF parses a Received-SPF header (RFC 7208)
+func parseReceivedSPF(value string) *AuthResult {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	result := &AuthResult{
+		Result:     strings.ToLower(fields[0]),
+		Properties: make(map[string]string),
+	}
+
+	// Key-value pairs follow the comment
+	for _, pair := range splitParams(stripHeaderComments(value[len(fields[0]):])) {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 {
+			result.Properties[strings.ToLower(kv[0])] = unquoteParam(kv[1])
+		}
+	}
+
+	return result
+}
+
+// isReceivedSPFFrom checks if a Received-SPF header was added by the given host,
+// named either in the receiver key or at the start of the comment
+func isReceivedSPFFrom(value, host string) bool {
+	if result := parseReceivedSPF(value); result != nil && strings.EqualFold(result.Properties["receiver"], host) {
+		return true
+	}
+
+	if open := strings.Index(value, "("); open >= 0 {
+		comment := strings.TrimSpace(value[open+1:])
+		if end := strings.IndexAny(comment, ": )"); end > 0 {
+			return strings.EqualFold(comment[:end], host)
+		}
+	}
+	return false
+}
+
+// parseDKIMSignature extracts the domain, selector, algorithm and signed headers
+// of a DKIM-Signature tag list (RFC 6376)
+func parseDKIMSignature(value string) *DKIMSignature {
+	signature := &DKIMSignature{}
+
+	for _, tag := range strings.Split(value, ";") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tagValue := strings.Join(strings.Fields(kv[1]), "")
+
+		switch strings.TrimSpace(kv[0]) {
+		case "d":
+			signature.Domain = strings.ToLower(tagValue)
+		case "s":
+			signature.Selector = tagValue
+		case "a":
+			signature.Algorithm = strings.ToLower(tagValue)
+		case "h":
+			for _, header := range strings.Split(tagValue, ":") {
+				if header != "" {
+					signature.Headers = append(signature.Headers, strings.ToLower(header))
+				}
+			}
+		}
+	}
+
+	if signature.Domain == "" {
+		return nil
+	}
+	return signature
+}
+
+// isAuthenticated checks for a DMARC pass or a DKIM pass aligned with the From domain
+func isAuthenticated(results *AuthenticationResults, domain string) bool {
+	if domain == "" {
+		return false
+	}
+
+	// A DMARC result only counts if it was evaluated for this From domain
+	if results.DMARC != nil {
+		headerFrom := results.DMARC.Properties["header.from"]
+		if headerFrom == "" || strings.EqualFold(headerFrom, domain) {
+			return results.DMARC.Result == "pass"
+		}
+	}
+
+	for _, dkim := range results.DKIM {
+		signingDomain := strings.ToLower(dkim.Properties["header.d"])
+		if dkim.Result == "pass" && signingDomain != "" &&
+			(domain == signingDomain || strings.HasSuffix(domain, "."+signingDomain)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fromDomain returns the lower case domain of the first From address
+func fromDomain(tree *MIMENode) string {
+	addresses, ok := tree.ParsedHeader["from"].([]*Address)
+	if !ok || len(addresses) == 0 {
+		return ""
+	}
+
+	if at := strings.LastIndex(addresses[0].Address, "@"); at >= 0 {
+		return strings.ToLower(addresses[0].Address[at+1:])
+	}
+	return ""
+}
+
+// stripHeaderComments removes parenthesized comments outside of quoted strings
+func stripHeaderComments(value string) string {
+	var sb strings.Builder
+	depth := 0
+	inQuotes := false
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value) && (inQuotes || depth > 0):
+			if depth == 0 {
+				sb.WriteByte(c)
+				sb.WriteByte(value[i+1])
+			}
+			i++
+		case c == '"' && depth == 0:
+			inQuotes = !inQuotes
+			sb.WriteByte(c)
+		case c == '(' && !inQuotes:
+			depth++
+		case c == ')' && !inQuotes && depth > 0:
+			depth--
+			sb.WriteByte(' ')
+		default:
+			if depth == 0 {
+				sb.WriteByte(c)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// quotedFields splits value on whitespace, keeping quoted strings together
+func quotedFields(value string) []string {
+	fields := make([]string, 0)
+	inQuotes := false
+	start := -1
+
+	for i := 0; i <= len(value); i++ {
+		if i == len(value) || (!inQuotes && (value[i] == ' ' || value[i] == '\t' || value[i] == '\r' || value[i] == '\n')) {
+			if start >= 0 {
+				fields = append(fields, value[start:i])
+				start = -1
+			}
+			continue
+		}
+		if value[i] == '"' {
+			inQuotes = !inQuotes
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+
+	return fields
+}
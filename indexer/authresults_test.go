@@ -0,0 +1,185 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestGetAuthenticationResults(t *testing.T) {
+	email := `Authentication-Results: mx.example.net;
+ spf=pass (sender IP is 192.0.2.1) smtp.mailfrom=sender@example.com;
+ dkim=pass (2048-bit key) header.d=example.com header.s=mail header.i=@example.com;
+ dkim=fail reason="signature verification failed" header.d=lists.example.org;
+ dmarc=pass (p=REJECT) header.from=example.com
+Authentication-Results: forged.example.com; dmarc=fail header.from=example.com
+DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=example.com; s=mail;
+ h=From : To : Subject; bh=abc=; b=def=
+From: Sender <sender@mail.example.com>
+To: recipient@example.net
+Subject: Authenticated
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	results := GetAuthenticationResults(tree, "mx.example.net")
+	if results == nil {
+		t.Fatal("Expected authentication results, got nil")
+	}
+
+	if results.AuthServID != "mx.example.net" {
+		t.Errorf("Expected authserv-id mx.example.net, got %s", results.AuthServID)
+	}
+	if results.SPF == nil || results.SPF.Result != "pass" || results.SPF.Properties["smtp.mailfrom"] != "sender@example.com" {
+		t.Errorf("Expected spf pass for sender@example.com, got %+v", results.SPF)
+	}
+	if len(results.DKIM) != 2 {
+		t.Fatalf("Expected 2 DKIM results, got %d", len(results.DKIM))
+	}
+	if results.DKIM[0].Result != "pass" || results.DKIM[0].Properties["header.s"] != "mail" {
+		t.Errorf("Expected dkim pass with selector mail, got %+v", results.DKIM[0])
+	}
+	if results.DKIM[1].Result != "fail" || results.DKIM[1].Reason != "signature verification failed" {
+		t.Errorf("Expected dkim fail with reason, got %+v", results.DKIM[1])
+	}
+	if results.DMARC == nil || results.DMARC.Result != "pass" {
+		t.Errorf("Expected dmarc pass, got %+v", results.DMARC)
+	}
+	if !results.Verified {
+		t.Error("Expected message to be verified")
+	}
+
+	if len(results.DKIMSignatures) != 1 {
+		t.Fatalf("Expected 1 DKIM signature, got %d", len(results.DKIMSignatures))
+	}
+	signature := results.DKIMSignatures[0]
+	if signature.Domain != "example.com" || signature.Selector != "mail" || signature.Algorithm != "rsa-sha256" {
+		t.Errorf("Expected example.com/mail rsa-sha256, got %+v", signature)
+	}
+	if len(signature.Headers) != 3 || signature.Headers[0] != "from" || signature.Headers[2] != "subject" {
+		t.Errorf("Expected signed headers from, to, subject, got %v", signature.Headers)
+	}
+}
+
+func TestGetAuthenticationResultsAuthServID(t *testing.T) {
+	email := `Authentication-Results: forged.example.com; dmarc=pass header.from=example.com
+Authentication-Results: mx.example.net; dkim=pass header.d=other.example
+Received-SPF: softfail (mx.example.net: domain of transitioning sender@example.com) client-ip=192.0.2.1; envelope-from="sender@example.com"; helo=mail.example.com;
+From: sender@example.com
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	results := GetAuthenticationResults(tree, "mx.example.net")
+	if results == nil {
+		t.Fatal("Expected authentication results, got nil")
+	}
+
+	if results.DMARC != nil {
+		t.Errorf("Expected forged dmarc result to be ignored, got %+v", results.DMARC)
+	}
+	if results.Verified {
+		t.Error("Expected unaligned DKIM pass not to verify the message")
+	}
+	if results.SPF == nil || results.SPF.Result != "softfail" {
+		t.Fatalf("Expected spf softfail from Received-SPF, got %+v", results.SPF)
+	}
+	if results.SPF.Properties["client-ip"] != "192.0.2.1" || results.SPF.Properties["envelope-from"] != "sender@example.com" {
+		t.Errorf("Expected client-ip and envelope-from, got %v", results.SPF.Properties)
+	}
+}
+
+func TestGetAuthenticationResultsUntrusted(t *testing.T) {
+	email := `Authentication-Results: forged.example.com; dkim=pass header.d=example.com; dmarc=pass header.from=example.com
+Received-SPF: pass (forged.example.com: domain of sender@example.com designates 192.0.2.1 as permitted sender) receiver=forged.example.com;
+From: sender@example.com
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	// Without a trusted authserv-id the topmost header is only informational
+	results := GetAuthenticationResults(tree, "")
+	if results == nil || results.DMARC == nil || results.DMARC.Result != "pass" {
+		t.Fatalf("Expected dmarc pass from the topmost header, got %+v", results)
+	}
+	if results.Verified {
+		t.Error("Expected results of an untrusted server not to verify the message")
+	}
+
+	// Headers of other servers are ignored
+	results = GetAuthenticationResults(tree, "mx.example.net")
+	if results != nil {
+		t.Errorf("Expected nil for headers of other servers, got %+v", results)
+	}
+}
+
+func TestGetAuthenticationResultsForgedBelow(t *testing.T) {
+	email := `Authentication-Results: mx.example.net; dkim=fail header.d=bank.com; dmarc=fail header.from=bank.com
+Authentication-Results: mx.example.net; dkim=pass header.d=bank.com; dmarc=pass header.from=bank.com
+From: ceo@bank.com
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	results := GetAuthenticationResults(tree, "mx.example.net")
+	if results == nil {
+		t.Fatal("Expected authentication results, got nil")
+	}
+
+	// The lower header with the same authserv-id was added by the sender
+	if results.DMARC == nil || results.DMARC.Result != "fail" {
+		t.Errorf("Expected dmarc fail from the topmost header, got %+v", results.DMARC)
+	}
+	if len(results.DKIM) != 1 || results.DKIM[0].Result != "fail" {
+		t.Errorf("Expected a single dkim fail, got %+v", results.DKIM)
+	}
+	if results.Verified {
+		t.Error("Expected forged header below the real one not to verify the message")
+	}
+}
+
+func TestGetAuthenticationResultsDMARCOtherDomain(t *testing.T) {
+	email := `Authentication-Results: mx.example.net; dmarc=pass header.from=attacker.example
+From: ceo@bank.com
+From: someone@attacker.example
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	results := GetAuthenticationResults(tree, "mx.example.net")
+	if results == nil || results.DMARC == nil || results.DMARC.Result != "pass" {
+		t.Fatalf("Expected dmarc pass, got %+v", results)
+	}
+	if results.Verified {
+		t.Error("Expected dmarc pass for another domain not to verify the message")
+	}
+}
+
+func TestGetAuthenticationResultsNone(t *testing.T) {
+	tree, err := ParseMIME([]byte("From: sender@example.com\n\nHello"))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if results := GetAuthenticationResults(tree, ""); results != nil {
+		t.Errorf("Expected nil, got %+v", results)
+	}
+}
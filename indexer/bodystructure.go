@@ -21,6 +21,7 @@ type BodyStructureOptions struct {
 	SkipContentLocation   bool // Do not include Content-Location in the output
 	Body                  bool // Skip extension fields (needed for BODY)
 	AttachmentRFC822      bool // Treat message/rfc822 as attachment
+	ComputeMD5            bool // Compute the MD5 field for parts without a Content-MD5 header
 }
 
 // NewBodyStructure creates a new BodyStructure instance
@@ -158,6 +159,10 @@ func (bs *BodyStructure) getExtensionFields(node *MIMENode, options *BodyStructu
 	var contentMD5 interface{}
 	if md5, exists := node.ParsedHeader["content-md5"]; exists {
 		contentMD5 = md5
+	} else if options.ComputeMD5 && node.Multipart == "" {
+		if md5, err := ContentMD5(node); err == nil {
+			contentMD5 = md5
+		}
 	}
 
 	// Content-Disposition
@@ -382,24 +387,19 @@ func (bs *BodyStructure) formatAddresses(addrs interface{}) interface{} {
 	}
 
 	if addresses, ok := addrs.([]*Address); ok && len(addresses) > 0 {
-		result := make([]interface{}, len(addresses))
-		for i, addr := range addresses {
-			// Split email address into parts
-			parts := strings.Split(addr.Address, "@")
-			var mailbox, host string
-			if len(parts) == 2 {
-				mailbox = parts[0]
-				host = parts[1]
-			} else {
-				mailbox = addr.Address
-			}
-
-			result[i] = []interface{}{
-				addr.Name, // personal name
-				nil,       // SMTP source route (obsolete)
-				mailbox,   // mailbox name
-				host,      // domain name
+		result := make([]interface{}, 0, len(addresses))
+		for _, addr := range addresses {
+			if addr.IsGroup {
+				// Groups are delimited by a start entry with the group name as mailbox
+				// and an end entry with all fields NIL (RFC 3501)
+				result = append(result, []interface{}{nil, nil, addr.Name, nil})
+				for _, member := range addr.Group {
+					result = append(result, bs.formatAddress(member))
+				}
+				result = append(result, []interface{}{nil, nil, nil, nil})
+				continue
 			}
+			result = append(result, bs.formatAddress(addr))
 		}
 		return result
 	}
@@ -407,6 +407,26 @@ func (bs *BodyStructure) formatAddresses(addrs interface{}) interface{} {
 	return nil
 }
 
+// formatAddress converts a single mailbox to IMAP format
+func (bs *BodyStructure) formatAddress(addr *Address) []interface{} {
+	// Split email address into parts
+	parts := strings.Split(addr.Address, "@")
+	var mailbox, host string
+	if len(parts) == 2 {
+		mailbox = parts[0]
+		host = parts[1]
+	} else {
+		mailbox = addr.Address
+	}
+
+	return []interface{}{
+		addr.Name, // personal name
+		nil,       // SMTP source route (obsolete)
+		mailbox,   // mailbox name
+		host,      // domain name
+	}
+}
+
 // flatten converts all sub-arrays into one level array
 func (bs *BodyStructure) flatten(arr interface{}) []interface{} {
 	result := make([]interface{}, 0)
@@ -1,6 +1,7 @@
 package indexer
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -535,6 +536,47 @@ func TestFormatAddresses(t *testing.T) {
 	}
 }
 
+func TestFormatAddressesGroups(t *testing.T) {
+	bs := &BodyStructure{}
+
+	addresses := []*Address{
+		{Name: "Team", IsGroup: true, Group: []*Address{{Name: "Bob", Address: "b@example.com"}}},
+		{Name: "undisclosed-recipients", IsGroup: true, Group: []*Address{}},
+		{Address: "jane@test.org"},
+	}
+
+	expected := "((NIL NIL \"Team\" NIL) (\"Bob\" NIL \"b\" \"example.com\") (NIL NIL NIL NIL) " +
+		"(NIL NIL \"undisclosed-recipients\" NIL) (NIL NIL NIL NIL) " +
+		"(\"\" NIL \"jane\" \"test.org\"))"
+
+	if result := SerializeBodyStructure(bs.formatAddresses(addresses)); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestFormatAddressesGroupsJSONRoundTrip(t *testing.T) {
+	bs := &BodyStructure{}
+
+	// Parsed headers are stored as JSON, empty groups must survive it
+	data, err := json.Marshal([]*Address{
+		{Name: "undisclosed-recipients", IsGroup: true, Group: []*Address{}},
+		{Address: "jane@test.org"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal addresses: %v", err)
+	}
+
+	var addresses []*Address
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		t.Fatalf("Failed to unmarshal addresses: %v", err)
+	}
+
+	expected := "((NIL NIL \"undisclosed-recipients\" NIL) (NIL NIL NIL NIL) (\"\" NIL \"jane\" \"test.org\"))"
+	if result := SerializeBodyStructure(bs.formatAddresses(addresses)); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
 func TestComplexMultipartStructure(t *testing.T) {
 	// Create a complex nested structure: multipart/mixed containing multipart/alternative
 
@@ -757,3 +799,82 @@ func BenchmarkSerializeBodyStructure(b *testing.B) {
 		SerializeBodyStructure(structure)
 	}
 }
+
+func TestBodyStructureMatchesServedBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		embedded bool
+	}{
+		{
+			name: "CRLF line endings",
+			email: "Content-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\n\r\nLine one\r\nLine two\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nNo trailing newline\r\n" +
+				"--b\r\nContent-Type: application/octet-stream\r\nContent-Transfer-Encoding: base64\r\n\r\nAAAA\r\nBBBB\r\n" +
+				"--b--\r\n",
+		},
+		{
+			name: "LF line endings",
+			email: "Content-Type: multipart/mixed; boundary=\"b\"\n\n" +
+				"--b\nContent-Type: text/plain\n\n\nLine one\nLine two\n\n" +
+				"--b\nContent-Type: text/plain\n\nNo trailing newline\n" +
+				"--b\nContent-Type: application/octet-stream\nContent-Transfer-Encoding: base64\n\nAAAA\nBBBB\n" +
+				"--b--\n",
+		},
+		{
+			name: "Embedded message with LF line endings",
+			email: "Content-Type: multipart/mixed; boundary=\"outer\"\n\n" +
+				"--outer\nContent-Type: message/rfc822\n\n" +
+				"Subject: Forwarded\nContent-Type: multipart/mixed; boundary=\"b\"\n\n" +
+				"--b\nContent-Type: text/plain\n\n\nLine one\nLine two\n\n" +
+				"--b\nContent-Type: text/plain\n\nNo trailing newline\n" +
+				"--b\nContent-Type: application/octet-stream\nContent-Transfer-Encoding: base64\n\nAAAA\nBBBB\n" +
+				"--b--\n" +
+				"--outer--\n",
+			embedded: true,
+		},
+	}
+
+	// Bytes returned by FETCH BODY[n] for each part
+	served := []string{
+		"\r\nLine one\r\nLine two\r\n",
+		"No trailing newline",
+		"AAAA\r\nBBBB",
+	}
+	lines := []int{3, 1, 2}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			structure, ok := CreateBodyStructure(tree, &BodyStructureOptions{Body: true}).([]interface{})
+			parts := tree.ChildNodes
+			if tt.embedded && ok {
+				// The body structure of a message/rfc822 part follows its envelope
+				structure, ok = structure[0].([]interface{})[8].([]interface{})
+				parts = tree.ChildNodes[0].Message.ChildNodes
+			}
+			if !ok || len(structure) < len(served) {
+				t.Fatalf("Expected multipart structure, got %v", structure)
+			}
+
+			for i, expected := range served {
+				if body := string(parts[i].Body); body != expected {
+					t.Errorf("Part %d: expected body %q, got %q", i+1, expected, body)
+				}
+
+				part := structure[i].([]interface{})
+				if part[6] != len(expected) {
+					t.Errorf("Part %d: expected size %d, got %v", i+1, len(expected), part[6])
+				}
+				if part[0] == "text" && part[7] != lines[i] {
+					t.Errorf("Part %d: expected %d lines, got %v", i+1, lines[i], part[7])
+				}
+			}
+		})
+	}
+}
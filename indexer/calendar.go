@@ -0,0 +1,548 @@
+package indexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarInvite represents the invite metadata of an iCalendar event
+type CalendarInvite struct {
+	Method    string              `json:"method,omitempty"`
+	UID       string              `json:"uid,omitempty"`
+	Sequence  int                 `json:"sequence,omitempty"`
+	Status    string              `json:"status,omitempty"`
+	Summary   string              `json:"summary,omitempty"`
+	Location  string              `json:"location,omitempty"`
+	Start     time.Time           `json:"start"`
+	End       time.Time           `json:"end"`
+	AllDay    bool                `json:"allDay,omitempty"`
+	Floating  bool                `json:"floating,omitempty"` // Start and End are wall-clock times without a known zone
+	Organizer *CalendarAttendee   `json:"organizer,omitempty"`
+	Attendees []*CalendarAttendee `json:"attendees,omitempty"`
+}
+
+// CalendarAttendee represents the organizer or an attendee of an event
+type CalendarAttendee struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+	Role    string `json:"role,omitempty"`
+	Status  string `json:"status,omitempty"` // PARTSTAT, e.g. NEEDS-ACTION or ACCEPTED
+	RSVP    bool   `json:"rsvp,omitempty"`
+}
+
+// calendarProperty is a single unfolded iCalendar content line
+type calendarProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// GetCalendarInvite returns the parsed invite of the first text/calendar part
+// in a MIME tree, or nil if the message carries no readable invite
+func GetCalendarInvite(tree *MIMENode) *CalendarInvite {
+	node := findCalendarNode(tree)
+	if node == nil {
+		return nil
+	}
+
+	body, err := DecodeBody(node)
+	if err != nil {
+		return nil
+	}
+
+	invite, err := ParseCalendar(body)
+	if err != nil {
+		return nil
+	}
+
+	// The METHOD parameter of the Content-Type is authoritative for iMIP messages,
+	// it overrides the METHOD property of the calendar
+	if method := contentTypeOf(node).Params["method"]; method != "" {
+		invite.Method = strings.ToUpper(method)
+	}
+
+	return invite
+}
+
+// findCalendarNode searches the tree for a text/calendar or application/ics part
+func findCalendarNode(node *MIMENode) *MIMENode {
+	if node == nil {
+		return nil
+	}
+
+	contentType := contentTypeOf(node)
+	if (contentType.Type == "text" && strings.EqualFold(contentType.Subtype, "calendar")) ||
+		(contentType.Type == "application" && strings.EqualFold(contentType.Subtype, "ics")) {
+		return node
+	}
+
+	for _, child := range node.ChildNodes {
+		if found := findCalendarNode(child); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// ParseCalendar parses iCalendar data and returns the first event as an invite
+func ParseCalendar(data []byte) (*CalendarInvite, error) {
+	properties := parseCalendarLines(string(data))
+
+	if len(properties) == 0 || properties[0].name != "BEGIN" || !strings.EqualFold(properties[0].value, "VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar object")
+	}
+
+	invite := &CalendarInvite{}
+	timezones := parseCalendarTimezones(properties)
+	components := make([]string, 0)
+	foundEvent := false
+
+	for _, prop := range properties {
+		switch prop.name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(prop.value))
+			continue
+		case "END":
+			if len(components) > 0 {
+				if components[len(components)-1] == "VEVENT" {
+					foundEvent = true
+				}
+				components = components[:len(components)-1]
+			}
+			continue
+		}
+
+		if len(components) == 1 && prop.name == "METHOD" {
+			invite.Method = strings.ToUpper(prop.value)
+			continue
+		}
+
+		// Only properties of the first event are used, nested alarms are ignored
+		if foundEvent || len(components) != 2 || components[1] != "VEVENT" {
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			invite.UID = prop.value
+		case "SEQUENCE":
+			invite.Sequence, _ = strconv.Atoi(prop.value)
+		case "STATUS":
+			invite.Status = strings.ToUpper(prop.value)
+		case "SUMMARY":
+			invite.Summary = unescapeCalendarText(prop.value)
+		case "LOCATION":
+			invite.Location = unescapeCalendarText(prop.value)
+		case "DTSTART":
+			var floating bool
+			invite.Start, invite.AllDay, floating = parseCalendarTime(prop, timezones)
+			invite.Floating = invite.Floating || floating
+		case "DTEND":
+			var floating bool
+			invite.End, _, floating = parseCalendarTime(prop, timezones)
+			invite.Floating = invite.Floating || floating
+		case "ORGANIZER":
+			invite.Organizer = parseCalendarAttendee(prop)
+		case "ATTENDEE":
+			invite.Attendees = append(invite.Attendees, parseCalendarAttendee(prop))
+		}
+	}
+
+	if !foundEvent {
+		return nil, fmt.Errorf("no VEVENT found in calendar")
+	}
+
+	return invite, nil
+}
+
+// parseCalendarLines unfolds content lines and splits them into name, parameters and value
+func parseCalendarLines(data string) []*calendarProperty {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	properties := make([]*calendarProperty, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// The value starts at the first colon outside of quoted parameter values
+		colon := -1
+		inQuotes := false
+		for i := 0; i < len(line); i++ {
+			if line[i] == '"' {
+				inQuotes = !inQuotes
+			} else if line[i] == ':' && !inQuotes {
+				colon = i
+				break
+			}
+		}
+		if colon < 0 {
+			continue
+		}
+
+		parts := splitParams(line[:colon])
+		prop := &calendarProperty{
+			name:   strings.ToUpper(strings.TrimSpace(parts[0])),
+			params: make(map[string]string),
+			value:  line[colon+1:],
+		}
+		for _, param := range parts[1:] {
+			if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+				prop.params[strings.ToUpper(strings.TrimSpace(kv[0]))] = strings.Trim(kv[1], `"`)
+			}
+		}
+
+		properties = append(properties, prop)
+	}
+
+	return properties
+}
+
+// parseCalendarTime parses DATE and DATE-TIME values, honoring TZID parameters.
+// Times in an unknown zone are returned as floating wall-clock times in UTC
+func parseCalendarTime(prop *calendarProperty, timezones map[string]*calendarTimezone) (time.Time, bool, bool) {
+	value := strings.TrimSpace(prop.value)
+
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, false
+		}
+		return t, true, false
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, false
+		}
+		return t, false, false
+	}
+
+	wall, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+
+	tzid := prop.params["TZID"]
+	if tzid == "" {
+		return wall, false, true
+	}
+
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return inCalendarLocation(wall, loc), false, false
+	}
+	// Outlook uses Windows zone names
+	if name, ok := windowsTimezones[tzid]; ok {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return inCalendarLocation(wall, loc), false, false
+		}
+	}
+	if timezone, ok := timezones[tzid]; ok {
+		if offset, ok := timezone.offsetAt(wall); ok {
+			return inCalendarLocation(wall, time.FixedZone(tzid, offset)), false, false
+		}
+	}
+
+	return wall, false, true
+}
+
+// inCalendarLocation moves a wall-clock time into a location
+func inCalendarLocation(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+}
+
+// calendarTimezone is a VTIMEZONE component embedded in a calendar
+type calendarTimezone struct {
+	observances []*calendarObservance
+}
+
+// calendarObservance is a STANDARD or DAYLIGHT rule of a VTIMEZONE
+type calendarObservance struct {
+	start  time.Time // DTSTART as wall-clock time
+	offset int       // TZOFFSETTO in seconds east of UTC
+	month  int       // BYMONTH of a yearly RRULE, 0 if the observance does not repeat
+	week   int       // Week of the month of the BYDAY rule, negative counts from the end
+	day    time.Weekday
+}
+
+// parseCalendarTimezones collects the VTIMEZONE components of a calendar by TZID
+func parseCalendarTimezones(properties []*calendarProperty) map[string]*calendarTimezone {
+	timezones := make(map[string]*calendarTimezone)
+
+	var timezone *calendarTimezone
+	var observance *calendarObservance
+	var hasOffset bool
+
+	for _, prop := range properties {
+		value := strings.TrimSpace(prop.value)
+		switch prop.name {
+		case "BEGIN":
+			switch strings.ToUpper(value) {
+			case "VTIMEZONE":
+				timezone = &calendarTimezone{}
+			case "STANDARD", "DAYLIGHT":
+				if timezone != nil {
+					observance, hasOffset = &calendarObservance{}, false
+				}
+			}
+		case "END":
+			switch strings.ToUpper(value) {
+			case "VTIMEZONE":
+				timezone = nil
+			case "STANDARD", "DAYLIGHT":
+				if timezone != nil && observance != nil && hasOffset {
+					timezone.observances = append(timezone.observances, observance)
+				}
+				observance = nil
+			}
+		case "TZID":
+			if timezone != nil && observance == nil {
+				timezones[value] = timezone
+			}
+		case "DTSTART":
+			if observance != nil {
+				observance.start, _ = time.Parse("20060102T150405", value)
+			}
+		case "TZOFFSETTO":
+			if observance != nil {
+				observance.offset, hasOffset = parseUTCOffset(value)
+			}
+		case "RRULE":
+			if observance != nil {
+				observance.parseRule(value)
+			}
+		}
+	}
+
+	return timezones
+}
+
+// parseRule reads yearly rules like FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU, other
+// rules are ignored and the observance only applies from its DTSTART
+func (observance *calendarObservance) parseRule(rule string) {
+	var yearly bool
+	var month, week int
+	day := time.Weekday(-1)
+
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.ToUpper(strings.TrimSpace(kv[1]))
+		switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+		case "FREQ":
+			yearly = value == "YEARLY"
+		case "BYMONTH":
+			month, _ = strconv.Atoi(value)
+		case "BYDAY":
+			if len(value) > 2 {
+				week, _ = strconv.Atoi(value[:len(value)-2])
+				for i, name := range []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"} {
+					if value[len(value)-2:] == name {
+						day = time.Weekday(i)
+					}
+				}
+			}
+		}
+	}
+
+	if !yearly || month < 1 || month > 12 || week < -5 || week == 0 || week > 5 || day < 0 {
+		return
+	}
+	observance.month, observance.week, observance.day = month, week, day
+}
+
+// transition returns the wall-clock time the observance starts in a year
+func (observance *calendarObservance) transition(year int) time.Time {
+	if observance.month == 0 {
+		return observance.start
+	}
+
+	hour, min, sec := observance.start.Clock()
+	if observance.week > 0 {
+		first := time.Date(year, time.Month(observance.month), 1, hour, min, sec, 0, time.UTC)
+		shift := (int(observance.day) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, shift+7*(observance.week-1))
+	}
+
+	last := time.Date(year, time.Month(observance.month)+1, 0, hour, min, sec, 0, time.UTC)
+	shift := (int(last.Weekday()) - int(observance.day) + 7) % 7
+	return last.AddDate(0, 0, -shift-7*(-observance.week-1))
+}
+
+// offsetAt returns the UTC offset of the observance in effect at a wall-clock time
+func (timezone *calendarTimezone) offsetAt(wall time.Time) (int, bool) {
+	if len(timezone.observances) == 0 {
+		return 0, false
+	}
+
+	// Times before the first transition use the first observance
+	offset := timezone.observances[0].offset
+	var latest time.Time
+	for _, observance := range timezone.observances {
+		for _, year := range []int{wall.Year() - 1, wall.Year()} {
+			at := observance.transition(year)
+			if at.Before(observance.start) || at.After(wall) || at.Before(latest) {
+				continue
+			}
+			latest, offset = at, observance.offset
+		}
+	}
+
+	return offset, true
+}
+
+// parseUTCOffset parses UTC offsets like +0100 or -053000 into seconds
+func parseUTCOffset(value string) (int, bool) {
+	if (len(value) != 5 && len(value) != 7) || (value[0] != '+' && value[0] != '-') {
+		return 0, false
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, false
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, false
+		}
+	}
+
+	offset := hours*3600 + minutes*60 + seconds
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// windowsTimezones maps the Windows zone names used by Outlook and Exchange to
+// IANA zones, following the CLDR windowsZones table
+var windowsTimezones = map[string]string{
+	"Dateline Standard Time":          "Etc/GMT+12",
+	"UTC-11":                          "Etc/GMT+11",
+	"Hawaiian Standard Time":          "Pacific/Honolulu",
+	"Alaskan Standard Time":           "America/Anchorage",
+	"Pacific Standard Time (Mexico)":  "America/Tijuana",
+	"Pacific Standard Time":           "America/Los_Angeles",
+	"US Mountain Standard Time":       "America/Phoenix",
+	"Mountain Standard Time (Mexico)": "America/Mazatlan",
+	"Mountain Standard Time":          "America/Denver",
+	"Central America Standard Time":   "America/Guatemala",
+	"Central Standard Time":           "America/Chicago",
+	"Central Standard Time (Mexico)":  "America/Mexico_City",
+	"Canada Central Standard Time":    "America/Regina",
+	"SA Pacific Standard Time":        "America/Bogota",
+	"Eastern Standard Time":           "America/New_York",
+	"Eastern Standard Time (Mexico)":  "America/Cancun",
+	"US Eastern Standard Time":        "America/Indianapolis",
+	"Venezuela Standard Time":         "America/Caracas",
+	"Atlantic Standard Time":          "America/Halifax",
+	"SA Western Standard Time":        "America/La_Paz",
+	"Pacific SA Standard Time":        "America/Santiago",
+	"Newfoundland Standard Time":      "America/St_Johns",
+	"E. South America Standard Time":  "America/Sao_Paulo",
+	"Argentina Standard Time":         "America/Buenos_Aires",
+	"SA Eastern Standard Time":        "America/Cayenne",
+	"Greenland Standard Time":         "America/Godthab",
+	"UTC-02":                          "Etc/GMT+2",
+	"Azores Standard Time":            "Atlantic/Azores",
+	"Cape Verde Standard Time":        "Atlantic/Cape_Verde",
+	"UTC":                             "Etc/UTC",
+	"GMT Standard Time":               "Europe/London",
+	"Greenwich Standard Time":         "Atlantic/Reykjavik",
+	"Morocco Standard Time":           "Africa/Casablanca",
+	"W. Europe Standard Time":         "Europe/Berlin",
+	"Central Europe Standard Time":    "Europe/Budapest",
+	"Romance Standard Time":           "Europe/Paris",
+	"Central European Standard Time":  "Europe/Warsaw",
+	"W. Central Africa Standard Time": "Africa/Lagos",
+	"GTB Standard Time":               "Europe/Bucharest",
+	"E. Europe Standard Time":         "Europe/Chisinau",
+	"Egypt Standard Time":             "Africa/Cairo",
+	"FLE Standard Time":               "Europe/Kiev",
+	"Israel Standard Time":            "Asia/Jerusalem",
+	"South Africa Standard Time":      "Africa/Johannesburg",
+	"Turkey Standard Time":            "Europe/Istanbul",
+	"Jordan Standard Time":            "Asia/Amman",
+	"Arabic Standard Time":            "Asia/Baghdad",
+	"Arab Standard Time":              "Asia/Riyadh",
+	"Russian Standard Time":           "Europe/Moscow",
+	"E. Africa Standard Time":         "Africa/Nairobi",
+	"Iran Standard Time":              "Asia/Tehran",
+	"Arabian Standard Time":           "Asia/Dubai",
+	"Caucasus Standard Time":          "Asia/Yerevan",
+	"Afghanistan Standard Time":       "Asia/Kabul",
+	"West Asia Standard Time":         "Asia/Tashkent",
+	"Pakistan Standard Time":          "Asia/Karachi",
+	"India Standard Time":             "Asia/Calcutta",
+	"Sri Lanka Standard Time":         "Asia/Colombo",
+	"Nepal Standard Time":             "Asia/Katmandu",
+	"Central Asia Standard Time":      "Asia/Almaty",
+	"Bangladesh Standard Time":        "Asia/Dhaka",
+	"Myanmar Standard Time":           "Asia/Rangoon",
+	"SE Asia Standard Time":           "Asia/Bangkok",
+	"China Standard Time":             "Asia/Shanghai",
+	"Singapore Standard Time":         "Asia/Singapore",
+	"Taipei Standard Time":            "Asia/Taipei",
+	"W. Australia Standard Time":      "Australia/Perth",
+	"Tokyo Standard Time":             "Asia/Tokyo",
+	"Korea Standard Time":             "Asia/Seoul",
+	"Cen. Australia Standard Time":    "Australia/Adelaide",
+	"AUS Central Standard Time":       "Australia/Darwin",
+	"E. Australia Standard Time":      "Australia/Brisbane",
+	"AUS Eastern Standard Time":       "Australia/Sydney",
+	"West Pacific Standard Time":      "Pacific/Port_Moresby",
+	"Tasmania Standard Time":          "Australia/Hobart",
+	"New Zealand Standard Time":       "Pacific/Auckland",
+	"UTC+12":                          "Etc/GMT-12",
+	"Fiji Standard Time":              "Pacific/Fiji",
+	"Tonga Standard Time":             "Pacific/Tongatapu",
+	"Samoa Standard Time":             "Pacific/Apia",
+}
+
+// parseCalendarAttendee converts an ORGANIZER or ATTENDEE property
+func parseCalendarAttendee(prop *calendarProperty) *CalendarAttendee {
+	address := strings.TrimSpace(prop.value)
+	if len(address) > 7 && strings.EqualFold(address[:7], "mailto:") {
+		address = address[7:]
+	}
+
+	return &CalendarAttendee{
+		Name:    prop.params["CN"],
+		Address: address,
+		Role:    strings.ToUpper(prop.params["ROLE"]),
+		Status:  strings.ToUpper(prop.params["PARTSTAT"]),
+		RSVP:    strings.EqualFold(prop.params["RSVP"], "TRUE"),
+	}
+}
+
+// unescapeCalendarText reverts the escaping of iCalendar TEXT values
+func unescapeCalendarText(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(value[i])
+			}
+			continue
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
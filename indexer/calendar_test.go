@@ -0,0 +1,189 @@
+package indexer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCalendarInvite(t *testing.T) {
+	email := "From: organizer@example.com\r\n" +
+		"To: guest@example.com\r\n" +
+		"Subject: Invitation: Planning\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"cal\"\r\n" +
+		"\r\n" +
+		"--cal\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"You have been invited.\r\n" +
+		"--cal\r\n" +
+		"Content-Type: text/calendar; charset=utf-8; method=REQUEST\r\n" +
+		"Content-Transfer-Encoding: 7bit\r\n" +
+		"\r\n" +
+		"BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"METHOD:REQUEST\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:event-123@example.com\r\n" +
+		"SEQUENCE:2\r\n" +
+		"SUMMARY:Quarterly planning\\, Q1\r\n" +
+		"LOCATION:Room 4\r\n" +
+		"DTSTART:20241125T090000Z\r\n" +
+		"DTEND:20241125T103000Z\r\n" +
+		"ORGANIZER;CN=\"Doe, John\":mailto:organizer@example.com\r\n" +
+		"ATTENDEE;CN=Jane Smith;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:\r\n" +
+		" mailto:guest@example.com\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"SUMMARY:Reminder\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n" +
+		"--cal--\r\n"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	invite := GetCalendarInvite(tree)
+	if invite == nil {
+		t.Fatal("Expected calendar invite, got nil")
+	}
+
+	if invite.Method != "REQUEST" || invite.UID != "event-123@example.com" || invite.Sequence != 2 {
+		t.Errorf("Unexpected invite identity: %+v", invite)
+	}
+	if invite.Summary != "Quarterly planning, Q1" || invite.Location != "Room 4" {
+		t.Errorf("Unexpected summary/location: %q / %q", invite.Summary, invite.Location)
+	}
+	if !invite.Start.Equal(time.Date(2024, 11, 25, 9, 0, 0, 0, time.UTC)) || !invite.End.Equal(time.Date(2024, 11, 25, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected times: %v - %v", invite.Start, invite.End)
+	}
+	if invite.Organizer == nil || invite.Organizer.Name != "Doe, John" || invite.Organizer.Address != "organizer@example.com" {
+		t.Errorf("Unexpected organizer: %+v", invite.Organizer)
+	}
+	if len(invite.Attendees) != 1 {
+		t.Fatalf("Expected 1 attendee, got %d", len(invite.Attendees))
+	}
+	attendee := invite.Attendees[0]
+	if attendee.Name != "Jane Smith" || attendee.Address != "guest@example.com" || attendee.Status != "NEEDS-ACTION" || !attendee.RSVP {
+		t.Errorf("Unexpected attendee: %+v", attendee)
+	}
+
+	// The raw ICS stays available as an attachment
+	attachments := GetAttachments(tree)
+	if len(attachments) != 1 || attachments[0].ContentType != "text/calendar" {
+		t.Errorf("Expected text/calendar attachment, got %+v", attachments)
+	}
+}
+
+func TestParseCalendarAllDayEvent(t *testing.T) {
+	data := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Holiday\nDTSTART;VALUE=DATE:20241224\nDTEND;VALUE=DATE:20241227\nEND:VEVENT\nEND:VCALENDAR\n"
+
+	invite, err := ParseCalendar([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse calendar: %v", err)
+	}
+
+	if !invite.AllDay || !invite.Start.Equal(time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected all-day event starting 2024-12-24, got %v (allDay=%t)", invite.Start, invite.AllDay)
+	}
+	if invite.Method != "" {
+		t.Errorf("Expected no method, got %q", invite.Method)
+	}
+}
+
+func TestParseCalendarInvalid(t *testing.T) {
+	if _, err := ParseCalendar([]byte("Not a calendar")); err == nil {
+		t.Error("Expected error for non-calendar data")
+	}
+	if _, err := ParseCalendar([]byte("BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VTODO\nEND:VCALENDAR")); err == nil {
+		t.Error("Expected error for calendar without events")
+	}
+}
+
+func TestParseCalendarTimezones(t *testing.T) {
+	vtimezone := "BEGIN:VTIMEZONE\n" +
+		"TZID:(UTC+01:00) Amsterdam\\, Berlin\\, Bern\\, Rome\n" +
+		"BEGIN:STANDARD\nDTSTART:16010101T030000\nTZOFFSETFROM:+0200\nTZOFFSETTO:+0100\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\nEND:STANDARD\n" +
+		"BEGIN:DAYLIGHT\nDTSTART:16010101T020000\nTZOFFSETFROM:+0100\nTZOFFSETTO:+0200\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\nEND:DAYLIGHT\n" +
+		"END:VTIMEZONE\n"
+
+	tests := []struct {
+		name     string
+		dtstart  string
+		expected time.Time
+		floating bool
+	}{
+		{
+			name:     "IANA zone",
+			dtstart:  "DTSTART;TZID=Europe/Berlin:20240715T100000",
+			expected: time.Date(2024, 7, 15, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Windows zone",
+			dtstart:  "DTSTART;TZID=W. Europe Standard Time:20240715T100000",
+			expected: time.Date(2024, 7, 15, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Embedded VTIMEZONE in summer",
+			dtstart:  "DTSTART;TZID=\"(UTC+01:00) Amsterdam\\, Berlin\\, Bern\\, Rome\":20240715T100000",
+			expected: time.Date(2024, 7, 15, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Embedded VTIMEZONE in winter",
+			dtstart:  "DTSTART;TZID=\"(UTC+01:00) Amsterdam\\, Berlin\\, Bern\\, Rome\":20240115T100000",
+			expected: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Unknown zone",
+			dtstart:  "DTSTART;TZID=Mars Standard Time:20240715T100000",
+			expected: time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC),
+			floating: true,
+		},
+		{
+			name:     "Local time without zone",
+			dtstart:  "DTSTART:20240715T100000",
+			expected: time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC),
+			floating: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "BEGIN:VCALENDAR\n" + vtimezone + "BEGIN:VEVENT\n" + tt.dtstart + "\nEND:VEVENT\nEND:VCALENDAR\n"
+
+			invite, err := ParseCalendar([]byte(data))
+			if err != nil {
+				t.Fatalf("Failed to parse calendar: %v", err)
+			}
+
+			if !invite.Start.Equal(tt.expected) {
+				t.Errorf("Expected start %v, got %v", tt.expected, invite.Start)
+			}
+			if invite.Floating != tt.floating {
+				t.Errorf("Expected floating %t, got %t", tt.floating, invite.Floating)
+			}
+		})
+	}
+}
+
+func TestGetCalendarInviteMethod(t *testing.T) {
+	email := "Content-Type: text/calendar; method=CANCEL\r\n" +
+		"\r\n" +
+		"BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:event-1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	invite := GetCalendarInvite(tree)
+	if invite == nil {
+		t.Fatal("Expected calendar invite, got nil")
+	}
+	if invite.Method != "CANCEL" {
+		t.Errorf("Expected method CANCEL from the Content-Type, got %q", invite.Method)
+	}
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrContentMD5Mismatch is returned when a part does not match its Content-MD5 header
+var ErrContentMD5Mismatch = errors.New("content-md5 mismatch")
+
+// ContentMD5 computes the Content-MD5 value (RFC 1864) of a part: the base64
+// encoded MD5 digest of the body with its transfer encoding reverted
+func ContentMD5(node *MIMENode) (string, error) {
+	content, err := DecodeBody(node)
+	if err != nil {
+		return "", err
+	}
+
+	sum := md5.Sum(content)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// VerifyContentMD5 checks all parts of a message that have a Content-MD5 header.
+// The returned error wraps ErrContentMD5Mismatch and names the IMAP section of
+// the first part that does not match
+func VerifyContentMD5(tree *MIMENode) error {
+	if tree == nil {
+		return nil
+	}
+
+	return verifyContentMD5(tree, rootSection(tree))
+}
+
+func verifyContentMD5(node *MIMENode, path string) error {
+	if node.Multipart != "" {
+		for i, child := range node.ChildNodes {
+			if err := verifyContentMD5(child, sectionPath(path, i+1)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if header, ok := node.ParsedHeader["content-md5"].(string); ok {
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+		if err != nil || len(expected) != md5.Size {
+			return fmt.Errorf("%w: invalid header in section %s", ErrContentMD5Mismatch, path)
+		}
+
+		content, err := DecodeBody(node)
+		if err != nil {
+			return fmt.Errorf("%w: undecodable body in section %s", ErrContentMD5Mismatch, path)
+		}
+		if sum := md5.Sum(content); !bytes.Equal(sum[:], expected) {
+			return fmt.Errorf("%w: section %s", ErrContentMD5Mismatch, path)
+		}
+	}
+
+	// Parts of an embedded message are numbered below the message/rfc822 part
+	if node.Message != nil {
+		if node.Message.Multipart != "" {
+			return verifyContentMD5(node.Message, path)
+		}
+		return verifyContentMD5(node.Message, sectionPath(path, 1))
+	}
+	return nil
+}
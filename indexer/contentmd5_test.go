@@ -0,0 +1,118 @@
+package indexer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContentMD5(t *testing.T) {
+	email := "Content-Type: application/octet-stream\r\nContent-Transfer-Encoding: base64\r\n\r\nAAEC"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	// The digest is computed over the decoded content
+	md5, err := ContentMD5(tree)
+	if err != nil {
+		t.Fatalf("Failed to compute Content-MD5: %v", err)
+	}
+	if md5 != "uV9n9h67A2GWIteY9F/C0w==" {
+		t.Errorf("Expected uV9n9h67A2GWIteY9F/C0w==, got %s", md5)
+	}
+}
+
+func TestVerifyContentMD5(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		section string
+	}{
+		{
+			name:  "matching digest",
+			email: "Content-MD5: ixqZU8RhEpaoJ6v4xHgE1w==\r\n\r\nHello",
+		},
+		{
+			name:  "no header",
+			email: "Subject: Test\r\n\r\nHello",
+		},
+		{
+			name:    "modified body",
+			email:   "Content-MD5: ixqZU8RhEpaoJ6v4xHgE1w==\r\n\r\nHello!",
+			section: "1",
+		},
+		{
+			name:    "invalid header",
+			email:   "Content-MD5: not a digest\r\n\r\nHello",
+			section: "1",
+		},
+		{
+			name: "mismatch in second part",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-MD5: ixqZU8RhEpaoJ6v4xHgE1w==\r\n\r\nHello\r\n" +
+				"--b\r\nContent-MD5: ixqZU8RhEpaoJ6v4xHgE1w==\r\n\r\nGoodbye\r\n" +
+				"--b--\r\n",
+			section: "2",
+		},
+		{
+			name: "mismatch in embedded message",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\n\r\nCover\r\n" +
+				"--b\r\nContent-Type: message/rfc822\r\n\r\nContent-MD5: ixqZU8RhEpaoJ6v4xHgE1w==\r\n\r\nChanged\r\n" +
+				"--b--\r\n",
+			section: "2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			err = VerifyContentMD5(tree)
+			if tt.section == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrContentMD5Mismatch) {
+				t.Fatalf("Expected ErrContentMD5Mismatch, got %v", err)
+			}
+			if !strings.HasSuffix(err.Error(), "section "+tt.section) {
+				t.Errorf("Expected error for section %s, got %v", tt.section, err)
+			}
+		})
+	}
+}
+
+func TestBodyStructureComputeMD5(t *testing.T) {
+	email := "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+		"--b\r\nContent-Type: text/plain\r\nContent-MD5: Q2hlY2sgSW50ZWdyaXR5IQ==\r\n\r\nWorld\r\n" +
+		"--b--\r\n"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	serialized := SerializeBodyStructure(CreateBodyStructure(tree, nil))
+	if strings.Contains(serialized, "ixqZU8RhEpaoJ6v4xHgE1w==") {
+		t.Errorf("Expected no computed MD5 by default, got %s", serialized)
+	}
+
+	// Header values are kept, missing ones are computed
+	serialized = SerializeBodyStructure(CreateBodyStructure(tree, &BodyStructureOptions{ComputeMD5: true}))
+	if !strings.Contains(serialized, `"ixqZU8RhEpaoJ6v4xHgE1w=="`) {
+		t.Errorf("Expected computed MD5, got %s", serialized)
+	}
+	if !strings.Contains(serialized, `"Q2hlY2sgSW50ZWdyaXR5IQ=="`) {
+		t.Errorf("Expected MD5 from header, got %s", serialized)
+	}
+}
@@ -0,0 +1,102 @@
+package indexer
+
+import (
+	"bytes"
+	"errors"
+)
+
+// DefaultMaxHeaderLength is the default limit for a single header including its folded lines
+const DefaultMaxHeaderLength = 32 * 1024
+
+var (
+	// ErrHeaderTooLarge is returned when the header block exceeds MaxHeaderSize
+	ErrHeaderTooLarge = errors.New("message header block too large")
+	// ErrHeaderLineTooLong is returned when a single header exceeds MaxHeaderLength
+	ErrHeaderLineTooLong = errors.New("message header too long")
+)
+
+// HeaderOptions contains the limits enforced by SanitizeHeaders.
+// Zero values use the defaults
+type HeaderOptions struct {
+	MaxHeaderSize   int // Maximum size in bytes of the header block
+	MaxHeaderLength int // Maximum size in bytes of a single header with its folded lines
+}
+
+// SanitizeHeaders checks the header block of a message before it is stored and
+// repairs it so the message can always be re-serialized safely. Header lines
+// are terminated with CRLF. Bare CR and NUL bytes are replaced with spaces.
+// In a CRLF message a bare LF is an injection attempt, so it is replaced too.
+// The body is returned unchanged
+func SanitizeHeaders(rfc822 []byte, options *HeaderOptions) ([]byte, error) {
+	if options == nil {
+		options = &HeaderOptions{}
+	}
+
+	header, body, hasBody := splitHeaderBlock(rfc822)
+	if len(header) > limit(options.MaxHeaderSize, DefaultMaxHeaderSize) {
+		return nil, ErrHeaderTooLarge
+	}
+
+	// Messages consistently using LF are normalized, in messages using CRLF
+	// only CRLF terminates a header line
+	var lines [][]byte
+	if bytes.Contains(header, []byte("\r\n")) {
+		lines = bytes.Split(header, []byte("\r\n"))
+	} else {
+		lines = bytes.Split(header, []byte("\n"))
+	}
+
+	var out bytes.Buffer
+	headerLength := 0
+	for i, line := range lines {
+		if i == len(lines)-1 && len(line) == 0 {
+			break // header block ended with a line break
+		}
+
+		// Replace byte by byte, 8-bit headers must not be reinterpreted as UTF-8
+		line = append([]byte(nil), line...)
+		for j, c := range line {
+			if c == '\r' || c == '\n' || c == 0 {
+				line[j] = ' '
+			}
+		}
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			headerLength += len(line) + 2
+		} else {
+			headerLength = len(line)
+		}
+		if headerLength > limit(options.MaxHeaderLength, DefaultMaxHeaderLength) {
+			return nil, ErrHeaderLineTooLong
+		}
+
+		out.Write(line)
+		out.WriteString("\r\n")
+	}
+
+	if hasBody {
+		out.WriteString("\r\n")
+		out.Write(body)
+	}
+
+	return out.Bytes(), nil
+}
+
+// splitHeaderBlock splits a message at the first empty line. The header block
+// includes the line break of its last line, the empty line is not part of either
+func splitHeaderBlock(rfc822 []byte) ([]byte, []byte, bool) {
+	pos := 0
+	for pos < len(rfc822) {
+		end := bytes.IndexByte(rfc822[pos:], '\n')
+		if end < 0 {
+			break
+		}
+		line := rfc822[pos : pos+end]
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
+			return rfc822[:pos], rfc822[pos+end+1:], true
+		}
+		pos += end + 1
+	}
+
+	return rfc822, nil, false
+}
@@ -0,0 +1,77 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "LF message is normalized",
+			input:    "From: a@example.com\nSubject: Hi\n\nBody\n",
+			expected: "From: a@example.com\r\nSubject: Hi\r\n\r\nBody\n",
+		},
+		{
+			name:     "bare LF injection in CRLF message",
+			input:    "From: a@example.com\r\nSubject: Hi\nBcc: victim@example.com\r\n\r\nBody",
+			expected: "From: a@example.com\r\nSubject: Hi Bcc: victim@example.com\r\n\r\nBody",
+		},
+		{
+			name:     "bare CR and NUL",
+			input:    "Subject: Hi\rBcc: victim@example.com\x00\r\n\r\nBody",
+			expected: "Subject: Hi Bcc: victim@example.com \r\n\r\nBody",
+		},
+		{
+			name:     "folded header and 8-bit bytes are kept",
+			input:    "Subject: Caf\xe9\r\n\tcontinued\r\n\r\nBody",
+			expected: "Subject: Caf\xe9\r\n\tcontinued\r\n\r\nBody",
+		},
+		{
+			name:     "header without body",
+			input:    "Subject: Hi",
+			expected: "Subject: Hi\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SanitizeHeaders([]byte(tt.input), nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeHeadersLimits(t *testing.T) {
+	longHeader := "Subject: " + strings.Repeat("x", 200) + "\r\n"
+	foldedHeader := "References: <a@example.com>\r\n" + strings.Repeat(" <b@example.com>\r\n", 20)
+
+	tests := []struct {
+		name     string
+		input    string
+		options  *HeaderOptions
+		expected error
+	}{
+		{"header block size", strings.Repeat("X-Test: value\r\n", 20) + "\r\nBody", &HeaderOptions{MaxHeaderSize: 100}, ErrHeaderTooLarge},
+		{"single header length", longHeader + "\r\nBody", &HeaderOptions{MaxHeaderLength: 100}, ErrHeaderLineTooLong},
+		{"folded header length", foldedHeader + "\r\nBody", &HeaderOptions{MaxHeaderLength: 100}, ErrHeaderLineTooLong},
+		{"within limits", longHeader + foldedHeader + "\r\nBody", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SanitizeHeaders([]byte(tt.input), tt.options); err != tt.expected {
+				t.Errorf("Expected error %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package indexer
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// htmlTokenType identifies the kind of a token produced by tokenizeHTML
+type htmlTokenType int
+
+const (
+	htmlTextToken htmlTokenType = iota
+	htmlStartTagToken
+	htmlEndTagToken
+	htmlCommentToken
+)
+
+// htmlAttribute is a single attribute of a start tag
+type htmlAttribute struct {
+	Name  string
+	Value string
+}
+
+// htmlToken is a piece of an HTML document. Data holds the unescaped text for
+// text tokens and the lower case tag name for tags
+type htmlToken struct {
+	Type        htmlTokenType
+	Data        string
+	Attrs       []htmlAttribute
+	SelfClosing bool
+}
+
+// attr returns the value of the named attribute
+func (t *htmlToken) attr(name string) (string, bool) {
+	for _, a := range t.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// htmlRawTextElements contain text that must not be parsed for markup
+var htmlRawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true, "xmp": true,
+}
+
+// tokenizeHTML splits an HTML document into text, tag and comment tokens. It
+// is lenient: anything that does not look like markup is treated as text
+func tokenizeHTML(input string) []htmlToken {
+	tokens := make([]htmlToken, 0)
+	var text strings.Builder
+
+	flushText := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, htmlToken{Type: htmlTextToken, Data: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+	}
+
+	pos := 0
+	for pos < len(input) {
+		lt := strings.IndexByte(input[pos:], '<')
+		if lt < 0 {
+			text.WriteString(input[pos:])
+			break
+		}
+		text.WriteString(input[pos : pos+lt])
+		pos += lt
+
+		rest := input[pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			flushText()
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				tokens = append(tokens, htmlToken{Type: htmlCommentToken, Data: rest[4:]})
+				pos = len(input)
+			} else {
+				tokens = append(tokens, htmlToken{Type: htmlCommentToken, Data: rest[4 : 4+end]})
+				pos += 4 + end + 3
+			}
+
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			// Doctype and processing instructions carry no content
+			flushText()
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				pos = len(input)
+			} else {
+				pos += end + 1
+			}
+
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			flushText()
+			name, _ := readTagName(rest, 2)
+			tokens = append(tokens, htmlToken{Type: htmlEndTagToken, Data: name})
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				pos = len(input)
+			} else {
+				pos += end + 1
+			}
+
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			flushText()
+			token, consumed := readStartTag(rest)
+			tokens = append(tokens, token)
+			pos += consumed
+
+			if htmlRawTextElements[token.Data] && !token.SelfClosing {
+				end := indexClosingTag(input[pos:], token.Data)
+				if content := input[pos : pos+end]; content != "" {
+					tokens = append(tokens, htmlToken{Type: htmlTextToken, Data: html.UnescapeString(content)})
+				}
+				pos += end
+			}
+
+		default:
+			text.WriteByte('<')
+			pos++
+		}
+	}
+	flushText()
+
+	return tokens
+}
+
+// indexClosingTag finds the case-insensitive closing tag of a raw text element,
+// returning the length of s if there is none
+func indexClosingTag(s, name string) int {
+	for pos := 0; pos < len(s); {
+		i := strings.Index(s[pos:], "</")
+		if i < 0 {
+			break
+		}
+		pos += i
+		if end := pos + 2 + len(name); end <= len(s) && strings.EqualFold(s[pos+2:end], name) {
+			return pos
+		}
+		pos += 2
+	}
+	return len(s)
+}
+
+// readTagName reads a lower case tag name starting at pos
+func readTagName(s string, pos int) (string, int) {
+	start := pos
+	for pos < len(s) && !isHTMLSpace(s[pos]) && s[pos] != '>' && s[pos] != '/' {
+		pos++
+	}
+	return strings.ToLower(s[start:pos]), pos
+}
+
+// readStartTag parses a start tag with its attributes and returns the number of bytes consumed
+func readStartTag(s string) (htmlToken, int) {
+	token := htmlToken{Type: htmlStartTagToken}
+	name, pos := readTagName(s, 1)
+	token.Data = name
+
+	for pos < len(s) {
+		for pos < len(s) && isHTMLSpace(s[pos]) {
+			pos++
+		}
+		if pos >= len(s) {
+			break
+		}
+		if s[pos] == '>' {
+			return token, pos + 1
+		}
+		if s[pos] == '/' {
+			if pos+1 < len(s) && s[pos+1] == '>' {
+				token.SelfClosing = true
+				return token, pos + 2
+			}
+			pos++
+			continue
+		}
+
+		start := pos
+		for pos < len(s) && !isHTMLSpace(s[pos]) && s[pos] != '=' && s[pos] != '>' && s[pos] != '/' {
+			pos++
+		}
+		attribute := htmlAttribute{Name: strings.ToLower(s[start:pos])}
+
+		for pos < len(s) && isHTMLSpace(s[pos]) {
+			pos++
+		}
+		if pos < len(s) && s[pos] == '=' {
+			pos++
+			for pos < len(s) && isHTMLSpace(s[pos]) {
+				pos++
+			}
+			if pos < len(s) && (s[pos] == '"' || s[pos] == '\'') {
+				quote := s[pos]
+				end := strings.IndexByte(s[pos+1:], quote)
+				if end < 0 {
+					attribute.Value = s[pos+1:]
+					pos = len(s)
+				} else {
+					attribute.Value = s[pos+1 : pos+1+end]
+					pos += end + 2
+				}
+			} else {
+				start = pos
+				for pos < len(s) && !isHTMLSpace(s[pos]) && s[pos] != '>' {
+					pos++
+				}
+				attribute.Value = s[start:pos]
+			}
+			attribute.Value = html.UnescapeString(attribute.Value)
+		}
+
+		token.Attrs = append(token.Attrs, attribute)
+	}
+
+	return token, len(s)
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// htmlTextWriter builds plain text line by line. Line breaks are requested
+// rather than written, so consecutive block elements do not pile up blank lines
+type htmlTextWriter struct {
+	lines      []string
+	line       strings.Builder
+	lineDepth  int
+	quoteDepth int
+	breaks     int
+	space      bool
+}
+
+// requestBreak asks for at least n line breaks before the next text
+func (w *htmlTextWriter) requestBreak(n int) {
+	if len(w.lines) == 0 && w.line.Len() == 0 {
+		return
+	}
+	if n > w.breaks {
+		w.breaks = n
+	}
+	w.space = false
+}
+
+// write appends text to the current line, applying pending breaks and spaces
+func (w *htmlTextWriter) write(s string) {
+	if s == "" {
+		return
+	}
+
+	if w.breaks > 0 {
+		// Blank lines between quote levels belong to the outer level
+		blankDepth := w.quoteDepth
+		if w.lineDepth < blankDepth {
+			blankDepth = w.lineDepth
+		}
+
+		w.flushLine()
+		for i := 1; i < w.breaks; i++ {
+			w.lines = append(w.lines, strings.TrimSpace(quotePrefix(blankDepth)))
+		}
+		w.breaks = 0
+	} else if w.space && w.line.Len() > 0 {
+		w.line.WriteByte(' ')
+	}
+	w.space = false
+
+	if w.line.Len() == 0 {
+		w.lineDepth = w.quoteDepth
+	}
+	w.line.WriteString(s)
+}
+
+// writeText writes text with HTML whitespace collapsing
+func (w *htmlTextWriter) writeText(s string) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			w.space = true
+		}
+		return
+	}
+
+	if isHTMLSpace(s[0]) {
+		w.space = true
+	}
+	w.write(strings.Join(words, " "))
+	if isHTMLSpace(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+// writePreformatted writes text keeping whitespace and line breaks as is
+func (w *htmlTextWriter) writePreformatted(s string) {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	for i, segment := range strings.Split(s, "\n") {
+		if i > 0 && (len(w.lines) > 0 || w.line.Len() > 0) {
+			w.breaks++
+		}
+		w.write(segment)
+	}
+}
+
+// flushLine moves the current line to the finished lines
+func (w *htmlTextWriter) flushLine() {
+	w.lines = append(w.lines, strings.TrimRight(quotePrefix(w.lineDepth)+w.line.String(), " "))
+	w.line.Reset()
+}
+
+// String returns the text written so far
+func (w *htmlTextWriter) String() string {
+	lines := w.lines
+	if w.line.Len() > 0 {
+		lines = append(lines, strings.TrimRight(quotePrefix(w.lineDepth)+w.line.String(), " "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// quotePrefix returns the line prefix for a blockquote depth
+func quotePrefix(depth int) string {
+	if depth <= 0 {
+		return ""
+	}
+	return strings.Repeat(">", depth) + " "
+}
+
+// htmlList tracks the numbering of an open list
+type htmlList struct {
+	ordered bool
+	counter int
+}
+
+// htmlLink tracks an open anchor so its target can be appended after the link text
+type htmlLink struct {
+	href string
+	text strings.Builder
+}
+
+var (
+	htmlParagraphElements = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+	htmlBlockElements = map[string]bool{
+		"div": true, "section": true, "article": true, "header": true, "footer": true,
+		"nav": true, "aside": true, "main": true, "table": true, "tr": true, "form": true,
+		"center": true, "address": true, "dl": true, "dt": true, "dd": true,
+		"figure": true, "figcaption": true, "fieldset": true, "caption": true,
+	}
+	htmlSkippedElements = map[string]bool{
+		"title": true, "script": true, "style": true, "template": true,
+	}
+	// htmlHeadElements may appear in the head, any other element implicitly
+	// closes it, as "</head>" is often missing
+	htmlHeadElements = map[string]bool{
+		"title": true, "meta": true, "link": true, "base": true, "style": true,
+		"script": true, "noscript": true, "template": true,
+	}
+)
+
+// htmlToText converts an HTML document to plain text, keeping paragraphs, list
+// bullets, link targets, blockquote markers and table rows
+func htmlToText(htmlContent string) string {
+	w := &htmlTextWriter{}
+
+	inHead := false
+	skipDepth := 0
+	preDepth := 0
+	cells := 0
+	lists := make([]*htmlList, 0)
+
+	// Links cannot be nested, an "a" start tag closes the open link
+	var link *htmlLink
+	closeLink := func() {
+		if link != nil && showLinkTarget(link) {
+			w.space = true
+			w.write("(" + link.href + ")")
+		}
+		link = nil
+	}
+
+	for _, token := range tokenizeHTML(htmlContent) {
+		if inHead {
+			switch {
+			case token.Type == htmlTextToken && strings.TrimSpace(token.Data) == "":
+				continue
+			case token.Type == htmlEndTagToken && token.Data == "head":
+				inHead = false
+				continue
+			case token.Type != htmlTextToken && htmlHeadElements[token.Data]:
+			case skipDepth > 0:
+			default:
+				inHead = false
+			}
+		}
+
+		switch token.Type {
+		case htmlTextToken:
+			if skipDepth > 0 || inHead {
+				continue
+			}
+			if link != nil {
+				link.text.WriteString(token.Data)
+			}
+			if preDepth > 0 {
+				w.writePreformatted(token.Data)
+			} else {
+				w.writeText(token.Data)
+			}
+
+		case htmlStartTagToken:
+			if token.Data == "head" {
+				inHead = !token.SelfClosing
+				continue
+			}
+			if inHead && !htmlSkippedElements[token.Data] {
+				continue // meta, link and base have no text
+			}
+			if htmlSkippedElements[token.Data] {
+				if !token.SelfClosing {
+					skipDepth++
+				}
+				continue
+			}
+
+			switch {
+			case htmlParagraphElements[token.Data]:
+				w.requestBreak(2)
+			case htmlBlockElements[token.Data]:
+				w.requestBreak(1)
+				if token.Data == "tr" {
+					cells = 0
+				}
+			}
+
+			switch token.Data {
+			case "br":
+				if len(w.lines) > 0 || w.line.Len() > 0 {
+					w.breaks++
+				}
+			case "hr":
+				w.requestBreak(1)
+				w.write("---")
+				w.requestBreak(1)
+			case "td", "th":
+				if cells > 0 {
+					w.space = true
+					w.write("|")
+					w.space = true
+				}
+				cells++
+			case "ul", "ol":
+				list := &htmlList{ordered: token.Data == "ol", counter: 1}
+				if start, ok := token.attr("start"); ok {
+					if n, err := strconv.Atoi(start); err == nil {
+						list.counter = n
+					}
+				}
+				lists = append(lists, list)
+				w.requestBreak(1)
+			case "li":
+				w.requestBreak(1)
+				bullet := "*"
+				if len(lists) > 0 && lists[len(lists)-1].ordered {
+					list := lists[len(lists)-1]
+					bullet = strconv.Itoa(list.counter) + "."
+					list.counter++
+				}
+				indent := ""
+				if len(lists) > 1 {
+					indent = strings.Repeat("  ", len(lists)-1)
+				}
+				w.write(indent + bullet)
+				w.space = true
+			case "blockquote":
+				w.requestBreak(2)
+				w.quoteDepth++
+			case "pre":
+				w.requestBreak(2)
+				preDepth++
+			case "a":
+				if link != nil {
+					closeLink()
+					w.space = true // the target separates the link texts
+				}
+				href, _ := token.attr("href")
+				link = &htmlLink{href: strings.TrimSpace(href)}
+			}
+
+		case htmlEndTagToken:
+			if htmlSkippedElements[token.Data] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+
+			switch {
+			case htmlParagraphElements[token.Data]:
+				w.requestBreak(2)
+			case htmlBlockElements[token.Data]:
+				w.requestBreak(1)
+			}
+
+			switch token.Data {
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				w.requestBreak(1)
+			case "li":
+				w.requestBreak(1)
+			case "blockquote":
+				w.requestBreak(2)
+				if w.quoteDepth > 0 {
+					w.quoteDepth--
+				}
+			case "pre":
+				w.requestBreak(2)
+				if preDepth > 0 {
+					preDepth--
+				}
+			case "a":
+				closeLink()
+			}
+		}
+	}
+
+	return w.String()
+}
+
+// showLinkTarget checks if the target of a link adds information to its text
+func showLinkTarget(link *htmlLink) bool {
+	if link.href == "" || strings.HasPrefix(link.href, "#") {
+		return false
+	}
+
+	lowerHref := strings.ToLower(link.href)
+	if strings.HasPrefix(lowerHref, "javascript:") || strings.HasPrefix(lowerHref, "cid:") {
+		return false
+	}
+
+	text := strings.TrimSpace(link.text.String())
+	return text != link.href && text != strings.TrimPrefix(link.href, "mailto:")
+}
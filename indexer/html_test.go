@@ -0,0 +1,136 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLToText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs and headings",
+			input:    "<h1>Title</h1><p>Paragraph</p><p>Second</p>",
+			expected: "Title\n\nParagraph\n\nSecond",
+		},
+		{
+			name:     "line breaks and divs",
+			input:    "<div>First line<br>Second line</div><div>Third</div>",
+			expected: "First line\nSecond line\nThird",
+		},
+		{
+			name:     "whitespace collapsed",
+			input:    "<p>  Lots   of\n\tspace  </p>",
+			expected: "Lots of space",
+		},
+		{
+			name:     "scripts, styles and head removed",
+			input:    "<html><head><title>T</title><style>p{}</style></head><body><script>alert('<p>')</script>Visible</body></html>",
+			expected: "Visible",
+		},
+		{
+			name:     "entities decoded",
+			input:    "a &lt; b &amp;&amp; c&nbsp;d &#8364;",
+			expected: "a < b && c d €",
+		},
+		{
+			name:     "unordered list",
+			input:    "<p>Items:</p><ul><li>One</li><li>Two</li></ul>After",
+			expected: "Items:\n\n* One\n* Two\nAfter",
+		},
+		{
+			name:     "ordered list with start",
+			input:    "<ol start=\"3\"><li>Three<li>Four</ol>",
+			expected: "3. Three\n4. Four",
+		},
+		{
+			name:     "link targets",
+			input:    `Read <a href="https://example.com/post">the post</a> or <a href="https://example.com">https://example.com</a>`,
+			expected: "Read the post (https://example.com/post) or https://example.com",
+		},
+		{
+			name:     "blockquote markers",
+			input:    "<p>Reply</p><blockquote><p>Quoted</p><blockquote>Nested</blockquote></blockquote><p>End</p>",
+			expected: "Reply\n\n> Quoted\n>\n>> Nested\n\nEnd",
+		},
+		{
+			name:     "tables",
+			input:    "<table><tr><th>Name</th><th>Qty</th></tr><tr><td>Apple</td><td>3</td></tr></table>",
+			expected: "Name | Qty\nApple | 3",
+		},
+		{
+			name:     "preformatted text",
+			input:    "<pre>line 1\n  indented\n\nline 4</pre>",
+			expected: "line 1\n  indented\n\nline 4",
+		},
+		{
+			name:     "head without end tag",
+			input:    "<html><head><meta charset=\"utf-8\"><title>T</title>\n<body><p>Visible</p></body></html>",
+			expected: "Visible",
+		},
+		{
+			name:     "head closed by content",
+			input:    "<head><style>p{}</style><p>Visible</p>",
+			expected: "Visible",
+		},
+		{
+			name:     "nested links",
+			input:    `<a href="https://a.example">A <a href="https://b.example">B</a> after</a>`,
+			expected: "A (https://a.example) B (https://b.example) after",
+		},
+		{
+			name:     "comments and stray brackets",
+			input:    "<!-- hidden -->1 < 2 <!DOCTYPE html>ok",
+			expected: "1 < 2 ok",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := htmlToText(tc.input); result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTMLToTextUnclosedLinks(t *testing.T) {
+	input := strings.Repeat(`<a href="https://example.com">x`, 30000)
+
+	start := time.Now()
+	htmlToText(input)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected linear time for unclosed links, took %v", elapsed)
+	}
+}
+
+func TestTokenizeHTMLAttributes(t *testing.T) {
+	tokens := tokenizeHTML(`<IMG SRC="a.png" alt='A &amp; B' width=10 hidden/>`)
+	if len(tokens) != 1 {
+		t.Fatalf("Expected 1 token, got %d", len(tokens))
+	}
+
+	token := tokens[0]
+	if token.Type != htmlStartTagToken || token.Data != "img" || !token.SelfClosing {
+		t.Errorf("Expected self-closing img start tag, got %+v", token)
+	}
+
+	expected := []htmlAttribute{
+		{Name: "src", Value: "a.png"},
+		{Name: "alt", Value: "A & B"},
+		{Name: "width", Value: "10"},
+		{Name: "hidden"},
+	}
+	if len(token.Attrs) != len(expected) {
+		t.Fatalf("Expected %d attributes, got %+v", len(expected), token.Attrs)
+	}
+	for i, attr := range expected {
+		if token.Attrs[i] != attr {
+			t.Errorf("Attribute %d: expected %+v, got %+v", i, attr, token.Attrs[i])
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// SyntheticMessageIDDomain is the domain of generated Message-IDs, .invalid
+// guarantees they never collide with real ones
+const SyntheticMessageIDDomain = "synthetic.invalid"
+
+// MessageIdentity contains the Message-ID and Date used for threading and
+// deduplication, generated when the message does not provide usable values
+type MessageIdentity struct {
+	MessageID            string    `json:"messageId"`
+	Date                 time.Time `json:"date"`
+	SynthesizedMessageID bool      `json:"synthesizedMessageId,omitempty"`
+	SynthesizedDate      bool      `json:"synthesizedDate,omitempty"`
+}
+
+// Synthesized checks if any of the identifying headers was generated
+func (identity *MessageIdentity) Synthesized() bool {
+	return identity.SynthesizedMessageID || identity.SynthesizedDate
+}
+
+// GetMessageIdentity returns the Message-ID and Date of a message. A missing
+// Message-ID is replaced with a stable hash of the message, a missing or
+// unparsable Date with the date of the topmost Received header or internalDate
+func GetMessageIdentity(tree *MIMENode, internalDate time.Time) *MessageIdentity {
+	identity := &MessageIdentity{}
+	if tree == nil {
+		return identity
+	}
+
+	if messageID := firstMessageID(tree.ParsedHeader["message-id"]); messageID != "" {
+		identity.MessageID = messageID
+	} else {
+		identity.MessageID = syntheticMessageID(tree)
+		identity.SynthesizedMessageID = true
+	}
+
+	if date, ok := parseDateHeader(tree.ParsedHeader["date"]); ok {
+		identity.Date = date
+		return identity
+	}
+
+	identity.SynthesizedDate = true
+	if received := headerValues(tree, "received"); len(received) > 0 {
+		// The date follows the last semicolon of the trace header
+		if semicolon := strings.LastIndex(received[0], ";"); semicolon >= 0 {
+			if date, ok := parseDateHeader(received[0][semicolon+1:]); ok {
+				identity.Date = date
+				return identity
+			}
+		}
+	}
+	identity.Date = internalDate
+
+	return identity
+}
+
+// firstMessageID returns the first non-empty Message-ID, repeated headers are parsed as a list
+func firstMessageID(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []string:
+		for _, messageID := range v {
+			if messageID = strings.TrimSpace(messageID); messageID != "" {
+				return messageID
+			}
+		}
+	}
+	return ""
+}
+
+// parseDateHeader parses an RFC 5322 date, for repeated headers the first
+// value that parses wins
+func parseDateHeader(value interface{}) (time.Time, bool) {
+	var dates []string
+	switch v := value.(type) {
+	case string:
+		dates = []string{v}
+	case []string:
+		dates = v
+	default:
+		return time.Time{}, false
+	}
+
+	for _, date := range dates {
+		if t, err := mail.ParseDate(strings.TrimSpace(date)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// syntheticMessageID hashes the originator headers and the content of a message.
+// Trace headers are left out so copies delivered to different recipients get the same ID
+func syntheticMessageID(tree *MIMENode) string {
+	h := sha256.New()
+
+	for _, line := range tree.Header {
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+		switch name {
+		case "from", "sender", "to", "cc", "subject", "date", "in-reply-to", "references":
+			h.Write([]byte(line))
+			h.Write([]byte("\r\n"))
+		}
+	}
+
+	// A message without a body has no content after the header block
+	if source, start := Serialize(tree), headerBlockLength(tree); start < len(source) {
+		h.Write(source[start:])
+	}
+
+	return "<" + hex.EncodeToString(h.Sum(nil)[:16]) + "@" + SyntheticMessageIDDomain + ">"
+}
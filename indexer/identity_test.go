@@ -0,0 +1,119 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMessageIdentity(t *testing.T) {
+	internalDate := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                 string
+		email                string
+		expectedMessageID    string
+		expectedDate         time.Time
+		synthesizedMessageID bool
+		synthesizedDate      bool
+	}{
+		{
+			name: "complete headers",
+			email: `Message-ID: <abc@example.com>
+Date: Tue, 1 Jul 2003 10:52:37 +0200
+From: sender@example.com
+
+Hello`,
+			expectedMessageID: "<abc@example.com>",
+			expectedDate:      time.Date(2003, 7, 1, 8, 52, 37, 0, time.UTC),
+		},
+		{
+			name: "repeated message-id",
+			email: `Message-ID:
+Message-ID: <first@example.com>
+Message-ID: <second@example.com>
+Date: Tue, 1 Jul 2003 10:52:37 +0200
+From: sender@example.com
+
+Hello`,
+			expectedMessageID: "<first@example.com>",
+			expectedDate:      time.Date(2003, 7, 1, 8, 52, 37, 0, time.UTC),
+		},
+		{
+			name: "repeated date",
+			email: `Message-ID: <abc@example.com>
+Date: Tue, 1 Jul 2003 10:52:37 +0200
+Date: garbage
+Date: Wed, 2 Jul 2003 10:52:37 +0200
+From: sender@example.com
+
+Hello`,
+			expectedMessageID: "<abc@example.com>",
+			expectedDate:      time.Date(2003, 7, 1, 8, 52, 37, 0, time.UTC),
+		},
+		{
+			name: "date from received header",
+			email: `Received: from mail.example.com by mx.example.net; Wed, 2 Jul 2003 09:00:00 +0000
+Received: from client by mail.example.com; Wed, 2 Jul 2003 08:59:00 +0000
+Date: yesterday
+From: sender@example.com
+
+Hello`,
+			expectedDate:         time.Date(2003, 7, 2, 9, 0, 0, 0, time.UTC),
+			synthesizedMessageID: true,
+			synthesizedDate:      true,
+		},
+		{
+			name: "internal date fallback",
+			email: `From: sender@example.com
+
+Hello`,
+			expectedDate:         internalDate,
+			synthesizedMessageID: true,
+			synthesizedDate:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			identity := GetMessageIdentity(tree, internalDate)
+			if tt.expectedMessageID != "" && identity.MessageID != tt.expectedMessageID {
+				t.Errorf("Expected Message-ID %s, got %s", tt.expectedMessageID, identity.MessageID)
+			}
+			if !identity.Date.Equal(tt.expectedDate) {
+				t.Errorf("Expected date %v, got %v", tt.expectedDate, identity.Date)
+			}
+			if identity.SynthesizedMessageID != tt.synthesizedMessageID || identity.SynthesizedDate != tt.synthesizedDate {
+				t.Errorf("Expected synthesized %v/%v, got %v/%v", tt.synthesizedMessageID, tt.synthesizedDate,
+					identity.SynthesizedMessageID, identity.SynthesizedDate)
+			}
+			if identity.Synthesized() != (tt.synthesizedMessageID || tt.synthesizedDate) {
+				t.Errorf("Expected Synthesized() to reflect the flags")
+			}
+			if tt.synthesizedMessageID && !strings.HasSuffix(identity.MessageID, "@"+SyntheticMessageIDDomain+">") {
+				t.Errorf("Expected synthetic Message-ID, got %s", identity.MessageID)
+			}
+		})
+	}
+}
+
+func TestSyntheticMessageIDIsStable(t *testing.T) {
+	message := "From: sender@example.com\nTo: a@example.com\nSubject: Report\n\nQuarterly numbers"
+
+	first, _ := ParseMIME([]byte("Received: from a by mx1; Wed, 2 Jul 2003 09:00:00 +0000\n" + message))
+	second, _ := ParseMIME([]byte("Received: from a by mx2; Wed, 2 Jul 2003 09:00:05 +0000\n" + message))
+	other, _ := ParseMIME([]byte(strings.Replace(message, "Quarterly", "Yearly", 1)))
+
+	firstID := GetMessageIdentity(first, time.Time{}).MessageID
+	if secondID := GetMessageIdentity(second, time.Time{}).MessageID; firstID != secondID {
+		t.Errorf("Expected the same ID for copies with different trace headers, got %s and %s", firstID, secondID)
+	}
+	if otherID := GetMessageIdentity(other, time.Time{}).MessageID; firstID == otherID {
+		t.Errorf("Expected different IDs for different content, got %s twice", firstID)
+	}
+}
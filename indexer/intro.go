@@ -0,0 +1,33 @@
+package indexer
+
+import (
+	"strings"
+)
+
+// IntroLength is the maximum number of characters in a generated intro
+const IntroLength = 250
+
+// GenerateIntro creates a short plain-text preview of the message content,
+// leaving out quoted replies and the signature
+func GenerateIntro(tree *MIMENode) string {
+	return createIntro(ExtractPrimaryText(tree), IntroLength)
+}
+
+// createIntro collapses whitespace in text and truncates it to maxLength characters,
+// including the ellipsis that marks a cut
+func createIntro(text string, maxLength int) string {
+	intro := strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(intro)
+	if len(runes) <= maxLength {
+		return intro
+	}
+
+	// Prefer cutting at a word boundary
+	truncated := string(runes[:maxLength-1])
+	if space := strings.LastIndex(truncated, " "); space > 0 {
+		truncated = truncated[:space]
+	}
+
+	return truncated + "…"
+}
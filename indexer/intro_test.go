@@ -0,0 +1,115 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerateIntroPlainText(t *testing.T) {
+	email := `From: sender@example.com
+To: recipient@example.com
+Subject: Re: Lunch
+Content-Type: text/plain; charset=utf-8
+
+Sounds good, see you at noon.
+
+On Mon, 23 Nov 2024 at 10:00, Jane <jane@example.com> wrote:
+> Lunch tomorrow?
+> Let me know.
+
+--
+John Doe
+Sales Department`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	intro := GenerateIntro(tree)
+	if intro != "Sounds good, see you at noon." {
+		t.Errorf("Unexpected intro: %q", intro)
+	}
+}
+
+func TestGenerateIntroPrefersPlainAlternative(t *testing.T) {
+	email := `From: sender@example.com
+Subject: Alternative
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/html; charset=utf-8
+
+<html><body><p>HTML version</p></body></html>
+
+--alt
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Plain version with soft=
+ break
+
+--alt--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	intro := GenerateIntro(tree)
+	if intro != "Plain version with soft break" {
+		t.Errorf("Unexpected intro: %q", intro)
+	}
+}
+
+func TestGenerateIntroFromHTML(t *testing.T) {
+	email := `From: sender@example.com
+Subject: HTML only
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: text/html; charset=utf-8
+
+<html><head><style>p { color: red; }</style></head>
+<body><p>Fish &amp; chips</p><p>Second paragraph</p></body></html>
+
+--mixed
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+
+Attached notes must not show up in the intro
+
+--mixed--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	intro := GenerateIntro(tree)
+	if intro != "Fish & chips Second paragraph" {
+		t.Errorf("Unexpected intro: %q", intro)
+	}
+}
+
+func TestCreateIntroTruncation(t *testing.T) {
+	text := strings.Repeat("wörd ", 100)
+
+	intro := createIntro(text, IntroLength)
+	if !strings.HasSuffix(intro, "…") {
+		t.Errorf("Expected truncated intro to end with ellipsis, got %q", intro)
+	}
+	if length := utf8.RuneCountInString(intro); length > IntroLength {
+		t.Errorf("Expected at most %d characters, got %d", IntroLength, length)
+	}
+	if strings.Contains(intro, "wö…") {
+		t.Errorf("Expected intro to be cut at a word boundary, got %q", intro)
+	}
+
+	// Without spaces the text is cut hard, the ellipsis still has to fit
+	intro = createIntro(strings.Repeat("ö", 300), IntroLength)
+	if length := utf8.RuneCountInString(intro); length != IntroLength {
+		t.Errorf("Expected %d characters, got %d", IntroLength, length)
+	}
+}
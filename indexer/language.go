@@ -0,0 +1,124 @@
+package indexer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLanguageMatches is the number of stopwords needed before a guess is trusted
+const minLanguageMatches = 3
+
+// languageStopwords contains frequent function words per ISO 639-1 language code.
+// Words shared by several languages are left out where possible
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "were", "you", "that", "this", "with", "for", "have", "not", "but", "will", "would", "from", "they", "which", "there", "been", "what", "about", "your", "our", "please", "thanks"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "sie", "mit", "auf", "für", "sich", "ein", "eine", "auch", "wir", "noch", "wie", "aber", "oder", "bitte", "danke", "haben", "wird", "werden", "dass"},
+	"fr": {"le", "les", "et", "est", "une", "pour", "dans", "qui", "que", "pas", "sur", "avec", "vous", "nous", "mais", "ce", "cette", "sont", "aux", "du", "des", "merci", "bonjour", "être", "avoir", "très"},
+	"es": {"el", "los", "las", "y", "es", "una", "para", "por", "con", "que", "del", "pero", "como", "más", "está", "son", "muy", "también", "gracias", "hola", "usted", "nosotros", "este", "esta", "cuando", "hay"},
+	"it": {"il", "gli", "della", "che", "è", "di", "una", "per", "con", "non", "sono", "anche", "come", "più", "questo", "questa", "grazie", "ciao", "nel", "alla", "degli", "essere", "molto", "perché", "ma", "ho"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "van", "dat", "met", "voor", "zijn", "maar", "ook", "wij", "jullie", "bedankt", "graag", "hebben", "worden", "naar", "bij", "wat", "er", "nog"},
+	"pt": {"os", "as", "um", "uma", "não", "para", "com", "que", "do", "da", "dos", "das", "mas", "como", "mais", "está", "são", "muito", "também", "obrigado", "obrigada", "você", "nós", "isso", "ao", "em"},
+	"sv": {"och", "är", "att", "det", "som", "inte", "jag", "med", "för", "på", "av", "till", "har", "men", "vi", "ni", "hej", "tack", "också", "kan", "ska", "eller", "från", "här", "vad", "mycket"},
+	"da": {"og", "er", "at", "det", "som", "ikke", "jeg", "med", "for", "på", "af", "til", "har", "men", "vi", "hej", "tak", "også", "kan", "skal", "eller", "fra", "her", "hvad", "meget", "være"},
+	"fi": {"ja", "on", "ei", "että", "se", "hän", "me", "te", "he", "kanssa", "mutta", "tai", "kun", "niin", "myös", "ole", "oli", "kiitos", "hei", "tämä", "joka", "mitä", "vain", "nyt", "sinä", "minä"},
+	"ru": {"и", "в", "не", "на", "что", "я", "с", "он", "как", "это", "по", "но", "они", "мы", "вы", "был", "для", "так", "его", "от", "спасибо", "привет", "если", "уже", "или", "только"},
+	"pl": {"i", "w", "nie", "na", "że", "się", "jest", "to", "z", "do", "jak", "ale", "tak", "dla", "czy", "jestem", "dziękuję", "cześć", "bardzo", "są", "ten", "ta", "już", "tylko", "może", "przez"},
+	"tr": {"ve", "bir", "bu", "için", "ile", "de", "da", "ne", "çok", "ama", "gibi", "daha", "var", "yok", "olarak", "teşekkürler", "merhaba", "sonra", "kadar", "ben", "sen", "biz", "siz", "onlar", "değil", "mi"},
+}
+
+// stopwordLanguages maps each stopword to the languages using it
+var stopwordLanguages = buildStopwordIndex()
+
+func buildStopwordIndex() map[string][]string {
+	index := make(map[string][]string)
+	for language, words := range languageStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], language)
+		}
+	}
+	return index
+}
+
+// GetLanguage detects the dominant language of the message text and returns
+// its ISO 639-1 code. The Content-Language header is used when the text is too
+// short or ambiguous. Returns an empty string if the language is unknown
+func GetLanguage(tree *MIMENode) string {
+	if tree == nil {
+		return ""
+	}
+
+	if language := DetectLanguage(ExtractPrimaryText(tree)); language != "" {
+		return language
+	}
+
+	if header, ok := tree.ParsedHeader["content-language"].(string); ok {
+		// e.g. "de-CH, en" uses the primary subtag of the first language
+		first := strings.TrimSpace(strings.SplitN(header, ",", 2)[0])
+		return strings.ToLower(strings.SplitN(first, "-", 2)[0])
+	}
+
+	return ""
+}
+
+// DetectLanguage returns the ISO 639-1 code of the dominant language of text,
+// or an empty string if it cannot be determined reliably
+func DetectLanguage(text string) string {
+	if language := detectScript(text); language != "" {
+		return language
+	}
+
+	scores := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		for _, language := range stopwordLanguages[word] {
+			scores[language]++
+		}
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, secondScore = language, score, bestScore
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+
+	// A tie between languages is not a reliable guess
+	if bestScore < minLanguageMatches || bestScore == secondScore {
+		return ""
+	}
+	return best
+}
+
+// detectScript identifies languages that can be told apart by their script alone
+func detectScript(text string) string {
+	var han, kana, hangul, letters int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+
+	// Scripts written without spaces are counted per character, so they only need
+	// to outweigh the letters of the other scripts
+	switch {
+	case kana > 0 && kana+han > letters:
+		return "ja"
+	case hangul > letters:
+		return "ko"
+	case han > letters:
+		return "zh"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"English", "Thanks for the update. I will have a look at the report and get back to you this week.", "en"},
+		{"German", "Vielen Dank für die Nachricht. Ich habe die Unterlagen noch nicht, aber wir werden sie bald haben.", "de"},
+		{"French", "Bonjour, merci pour votre message. Nous allons regarder les documents dans la semaine et vous répondre.", "fr"},
+		{"Spanish", "Hola, gracias por el mensaje. Los documentos están listos y son muy claros para nosotros.", "es"},
+		{"Italian", "Ciao, grazie per il messaggio. Non ho ancora visto i documenti ma sono molto interessato.", "it"},
+		{"Dutch", "Bedankt voor je bericht. Ik heb de documenten nog niet gezien maar ik kijk er graag naar.", "nl"},
+		{"Swedish", "Hej och tack för ditt meddelande. Jag har inte sett dokumenten men vi kan titta på det.", "sv"},
+		{"Russian", "Привет, спасибо за письмо. Я уже посмотрел документы, и это очень интересно для нас.", "ru"},
+		{"Japanese", "お世話になっております。資料を確認しました。", "ja"},
+		{"Chinese", "谢谢你的邮件，我已经看过文件了。", "zh"},
+		{"Korean", "메일 감사합니다. 문서를 확인했습니다.", "ko"},
+		{"Too short", "Hello there", ""},
+		{"No stopwords", "Invoice 2024-001 attached", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if language := DetectLanguage(tt.text); language != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, language)
+			}
+		})
+	}
+}
+
+func TestGetLanguageContentLanguageFallback(t *testing.T) {
+	email := `From: sender@example.com
+Content-Language: de-CH, en
+Content-Type: text/plain
+
+Rechnung 2024-001`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if language := GetLanguage(tree); language != "de" {
+		t.Errorf("Expected de, got %q", language)
+	}
+}
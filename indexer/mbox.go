@@ -0,0 +1,52 @@
+package indexer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// MboxWriter writes messages to an mbox file in the mboxrd format. Each message
+// is written as soon as it is added, so large mailboxes can be streamed
+type MboxWriter struct {
+	w io.Writer
+}
+
+// NewMboxWriter creates a writer appending messages to w
+func NewMboxWriter(w io.Writer) *MboxWriter {
+	return &MboxWriter{w: w}
+}
+
+// WriteMessage appends a message rebuilt with Serialize. sender and date are the
+// envelope sender and delivery time used in the "From " separator line. An empty
+// sender is written as MAILER-DAEMON and a zero date uses the message date
+func (m *MboxWriter) WriteMessage(tree *MIMENode, sender string, date time.Time) error {
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+	if date.IsZero() {
+		date = GetMessageIdentity(tree, time.Unix(0, 0)).Date
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("From " + strings.Join(strings.Fields(sender), "") + " " + date.UTC().Format(time.ANSIC) + "\n")
+
+	source := bytes.ReplaceAll(Serialize(tree), []byte("\r\n"), []byte("\n"))
+	source = bytes.TrimSuffix(source, []byte("\n"))
+	for _, line := range bytes.Split(source, []byte("\n")) {
+		// mboxrd quotes "From " lines including already quoted ones, so
+		// readers can restore the original by removing one ">"
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	// An empty line separates the message from the next "From " line
+	buf.WriteByte('\n')
+
+	_, err := m.w.Write(buf.Bytes())
+	return err
+}
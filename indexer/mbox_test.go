@@ -0,0 +1,35 @@
+package indexer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMboxWriter(t *testing.T) {
+	first, err := ParseMIME([]byte("From: sender@example.com\r\nSubject: First\r\n\r\nFrom the start\r\n>From quoted\r\nFrom: not a header\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+	second, err := ParseMIME([]byte("Date: Tue, 1 Jul 2003 10:52:37 +0200\r\nSubject: Second\r\n\r\nBody"))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := NewMboxWriter(&buf)
+	if err := writer.WriteMessage(first, "sender@example.com", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+	if err := writer.WriteMessage(second, "", time.Time{}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	expected := "From sender@example.com Fri Mar  1 12:00:00 2024\n" +
+		"From: sender@example.com\nSubject: First\n\n>From the start\n>>From quoted\nFrom: not a header\n\n" +
+		"From MAILER-DAEMON Tue Jul  1 08:52:37 2003\n" +
+		"Date: Tue, 1 Jul 2003 10:52:37 +0200\nSubject: Second\n\nBody\n\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
@@ -2,9 +2,10 @@ package indexer
 
 import (
 	"fmt"
-	"net/mail"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // MIMENode represents a node in the MIME tree
@@ -13,19 +14,58 @@ type MIMENode struct {
 	ChildNodes     []*MIMENode            `json:"childNodes,omitempty"`
 	Header         []string               `json:"header,omitempty"`
 	ParsedHeader   map[string]interface{} `json:"parsedHeader"`
-	Body           []byte                 `json:"body,omitempty"`
+	Body           []byte                 `json:"body,omitempty"`     // For multipart parts only the preamble
+	Epilogue       []byte                 `json:"epilogue,omitempty"` // Text after the closing boundary of a multipart part
 	Multipart      string                 `json:"multipart,omitempty"`
 	Boundary       string                 `json:"boundary,omitempty"`
 	ParentBoundary string                 `json:"parentBoundary,omitempty"`
 	LineCount      int                    `json:"lineCount,omitempty"`
 	Size           int                    `json:"size,omitempty"`
 	Message        *MIMENode              `json:"message,omitempty"`
+	Truncated      bool                   `json:"truncated,omitempty"`    // Parsing stopped at a ParseOptions limit
+	Unterminated   bool                   `json:"unterminated,omitempty"` // The multipart part has no closing boundary
+
+	// Source details that are only needed to serialize the part byte for byte
+	HeaderOnly     bool   `json:"headerOnly,omitempty"`     // No empty line follows the header block
+	BlankBody      bool   `json:"blankBody,omitempty"`      // The body is a single empty line
+	Delimiter      string `json:"delimiter,omitempty"`      // Boundary line before the part if it has transport padding
+	CloseDelimiter string `json:"closeDelimiter,omitempty"` // Closing boundary line if it has transport padding
 
 	// Internal fields for parsing
 	state      string
+	hasBody    bool
+	closed     bool
+	depth      int
+	headerSize int
+	headerFull bool
 	parentNode *MIMENode
 }
 
+// Default parser limits, generous for real mail but bounded for crafted messages
+const (
+	DefaultMaxDepth      = 50
+	DefaultMaxParts      = 1000
+	DefaultMaxHeaders    = 1000
+	DefaultMaxHeaderSize = 100 * 1024
+)
+
+// ParseOptions contains the limits that protect the parser against MIME bombs
+// and the recovery settings for malformed messages. Zero values use the defaults
+type ParseOptions struct {
+	MaxDepth      int  // Maximum nesting of multipart and message/rfc822 parts
+	MaxParts      int  // Maximum number of MIME parts in the message
+	MaxHeaders    int  // Maximum number of header lines per part
+	MaxHeaderSize int  // Maximum size in bytes of the header block of a part
+	Strict        bool // Disable the recovery fallbacks for malformed messages
+}
+
+// parseState is shared between a parser and the parsers of embedded messages
+// so limits apply to the message as a whole
+type parseState struct {
+	parts     int
+	truncated bool
+}
+
 // ValueParams represents a parsed header value with parameters
 type ValueParams struct {
 	Value     string            `json:"value"`
@@ -35,10 +75,13 @@ type ValueParams struct {
 	HasParams bool              `json:"hasParams,omitempty"`
 }
 
-// Address represents an email address
+// Address represents an email address. Groups (RFC 5322) have IsGroup set,
+// a Name and their members in Group but no Address
 type Address struct {
-	Name    string `json:"name,omitempty"`
-	Address string `json:"address"`
+	Name    string     `json:"name,omitempty"`
+	Address string     `json:"address"`
+	IsGroup bool       `json:"isGroup,omitempty"`
+	Group   []*Address `json:"group,omitempty"`
 }
 
 // MIMEParser handles parsing of RFC822 messages
@@ -46,9 +89,10 @@ type MIMEParser struct {
 	rfc822  string
 	pos     int
 	br      string
-	rawBody string
 	tree    *MIMENode
 	node    *MIMENode
+	options *ParseOptions
+	state   *parseState
 }
 
 // NewMIMEParser creates a new parser instance
@@ -61,11 +105,30 @@ func NewMIMEParser(rfc822 []byte) *MIMEParser {
 			ChildNodes:   make([]*MIMENode, 0),
 			ParsedHeader: make(map[string]interface{}),
 		},
+		options: &ParseOptions{},
+		state:   &parseState{},
 	}
 	parser.node = parser.createNode(parser.tree)
 	return parser
 }
 
+// NewMIMEParserWithOptions creates a new parser instance with custom limits
+func NewMIMEParserWithOptions(rfc822 []byte, options *ParseOptions) *MIMEParser {
+	parser := NewMIMEParser(rfc822)
+	if options != nil {
+		parser.options = options
+	}
+	return parser
+}
+
+// limit returns value, or fallback if value is not set
+func limit(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
 // Parse processes the message line by line
 func (p *MIMEParser) Parse() error {
 	var prevBr string
@@ -75,47 +138,61 @@ func (p *MIMEParser) Parse() error {
 
 		switch p.node.state {
 		case "header":
-			if p.rawBody != "" {
-				p.rawBody += prevBr + line
-			}
-
 			if line == "" {
 				p.processNodeHeader()
 				p.processContentType()
 				p.node.state = "body"
+			} else if p.node.headerFull || len(p.node.Header) >= limit(p.options.MaxHeaders, DefaultMaxHeaders) ||
+				p.node.headerSize+len(line) > limit(p.options.MaxHeaderSize, DefaultMaxHeaderSize) {
+				// The rest of the header block is dropped, a later line could be the
+				// continuation of a dropped header and corrupt the last kept one
+				p.node.headerFull = true
+				p.state.truncated = true
 			} else {
 				p.node.Header = append(p.node.Header, line)
+				p.node.headerSize += len(line) + len(p.br)
 			}
 
 		case "body":
-			p.rawBody += prevBr + line
-
-			if p.node.ParentBoundary != "" &&
-				(line == "--"+p.node.ParentBoundary ||
-					line == "--"+p.node.ParentBoundary+"--") {
-
-				if contentType, ok := p.node.ParsedHeader["content-type"].(*ValueParams); ok {
-					if contentType.Value == "message/rfc822" {
-						if len(p.node.Body) > 0 {
-							subParser := NewMIMEParser(p.node.Body)
-							subParser.Parse()
-							p.node.Message = subParser.GetResult()
-						}
-					}
-				}
+			// Boundary lines may be followed by transport padding (RFC 2046)
+			boundaryLine := strings.TrimRight(line, " \t")
+
+			if owner, closing := p.boundaryOwner(boundaryLine); owner != nil {
+				p.parseEmbeddedMessage(p.node)
 
-				if line == "--"+p.node.ParentBoundary {
-					p.node = p.createNode(p.node.parentNode)
+				if !closing {
+					if !p.canCreateNode() {
+						return nil
+					}
+					p.node = p.createNode(owner)
+					if line != boundaryLine {
+						p.node.Delimiter = line
+					}
 				} else {
-					p.node = p.node.parentNode
+					p.node = owner
+					p.node.closed = true
+					if line != boundaryLine {
+						p.node.CloseDelimiter = line
+					}
+				}
+			} else if p.node.closed {
+				// Everything after the closing boundary is the epilogue
+				p.node.Epilogue = append(p.node.Epilogue, []byte(prevBr+line)...)
+			} else if p.node.Boundary != "" && boundaryLine == "--"+p.node.Boundary {
+				if !p.canCreateNode() {
+					return nil
 				}
-			} else if p.node.Boundary != "" && line == "--"+p.node.Boundary {
 				p.node = p.createNode(p.node)
+				if line != boundaryLine {
+					p.node.Delimiter = line
+				}
 			} else {
-				if len(p.node.Body) > 0 {
+				// An empty first line is still part of the body
+				if p.node.hasBody {
 					p.node.Body = append(p.node.Body, []byte(prevBr+line)...)
 				} else {
 					p.node.Body = []byte(line)
+					p.node.hasBody = true
 				}
 			}
 
@@ -129,9 +206,82 @@ func (p *MIMEParser) Parse() error {
 		}
 	}
 
+	// The message ended inside a header block, e.g. a message without a body
+	if p.node.state == "header" {
+		p.node.HeaderOnly = true
+		p.processNodeHeader()
+		p.processContentType()
+		p.node.state = "body"
+	}
+
+	// Without an end boundary the last part is never followed by a boundary line
+	p.parseEmbeddedMessage(p.node)
+
 	return nil
 }
 
+// boundaryOwner returns the multipart node a boundary line belongs to and whether
+// it is a closing boundary. Unless parsing is strict, the boundaries of all
+// ancestors are checked, so a nested multipart without its end boundary does
+// not swallow the remaining parts of the message
+func (p *MIMEParser) boundaryOwner(line string) (*MIMENode, bool) {
+	if !strings.HasPrefix(line, "--") {
+		return nil, false
+	}
+
+	for ancestor := p.node.parentNode; ancestor != nil && !ancestor.RootNode; ancestor = ancestor.parentNode {
+		if ancestor.Boundary != "" {
+			if line == "--"+ancestor.Boundary {
+				return ancestor, false
+			}
+			if line == "--"+ancestor.Boundary+"--" {
+				return ancestor, true
+			}
+		}
+		if p.options.Strict {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// canCreateNode checks the part limit, parsing stops once it is reached
+func (p *MIMEParser) canCreateNode() bool {
+	if p.state.parts >= limit(p.options.MaxParts, DefaultMaxParts) {
+		p.state.truncated = true
+		return false
+	}
+	return true
+}
+
+// parseEmbeddedMessage parses the body of a message/rfc822 node, sharing the
+// limits of the enclosing message. Other nodes are left as they are
+func (p *MIMEParser) parseEmbeddedMessage(node *MIMENode) {
+	contentType, ok := node.ParsedHeader["content-type"].(*ValueParams)
+	if !ok || contentType.Value != "message/rfc822" || len(node.Body) == 0 || node.Message != nil {
+		return
+	}
+
+	if node.depth+1 >= limit(p.options.MaxDepth, DefaultMaxDepth) {
+		p.state.truncated = true
+		return
+	}
+
+	subParser := NewMIMEParserWithOptions(node.Body, p.options)
+	subParser.state = p.state
+	subParser.state.parts++
+	subParser.tree.ChildNodes[0].depth = node.depth + 1
+	subParser.Parse()
+	node.Message = subParser.GetResult()
+}
+
+var (
+	foldedLineRe = regexp.MustCompile(`^\s`)
+	headerKeyRe  = regexp.MustCompile(`^[a-zA-Z0-9\-\*]`)
+	headerFoldRe = regexp.MustCompile(`\s*\r?\n\s*`)
+)
+
 // readLine reads a line from the message body
 func (p *MIMEParser) readLine() string {
 	if p.pos >= len(p.rfc822) {
@@ -139,21 +289,23 @@ func (p *MIMEParser) readLine() string {
 		return ""
 	}
 
-	re := regexp.MustCompile(`(.*?)(\r?\n|$)`)
+	// Lines are found with a plain scan, parsing must stay linear in the message size
 	remaining := p.rfc822[p.pos:]
-	matches := re.FindStringSubmatch(remaining)
-
-	if matches != nil {
-		p.br = matches[2]
-		if p.br == "" && p.pos+len(matches[0]) < len(p.rfc822) {
-			p.br = "\n"
-		}
-		p.pos += len(matches[0])
-		return matches[1]
+	end := strings.IndexByte(remaining, '\n')
+	if end < 0 {
+		p.br = ""
+		p.pos = len(p.rfc822)
+		return remaining
 	}
 
-	p.br = ""
-	return ""
+	line := remaining[:end]
+	p.br = "\n"
+	if strings.HasSuffix(line, "\r") {
+		line = line[:len(line)-1]
+		p.br = "\r\n"
+	}
+	p.pos += end + 1
+	return line
 }
 
 // createNode creates a new node with default values
@@ -167,6 +319,12 @@ func (p *MIMEParser) createNode(parentNode *MIMENode) *MIMENode {
 		ParentBoundary: parentNode.Boundary,
 		parentNode:     parentNode,
 	}
+	if !parentNode.RootNode {
+		node.depth = parentNode.depth + 1
+	}
+	if p.state != nil {
+		p.state.parts++
+	}
 	parentNode.ChildNodes = append(parentNode.ChildNodes, node)
 	return node
 }
@@ -175,7 +333,7 @@ func (p *MIMEParser) createNode(parentNode *MIMENode) *MIMENode {
 func (p *MIMEParser) processNodeHeader() {
 	// Process folded headers
 	for i := len(p.node.Header) - 1; i >= 0; i-- {
-		if i > 0 && regexp.MustCompile(`^\s`).MatchString(p.node.Header[i]) {
+		if i > 0 && foldedLineRe.MatchString(p.node.Header[i]) {
 			p.node.Header[i-1] = p.node.Header[i-1] + "\r\n" + p.node.Header[i]
 			p.node.Header = append(p.node.Header[:i], p.node.Header[i+1:]...)
 		} else {
@@ -185,9 +343,14 @@ func (p *MIMEParser) processNodeHeader() {
 				value := strings.TrimSpace(parts[1])
 
 				// Validate key format
-				if regexp.MustCompile(`^[a-zA-Z0-9\-\*]`).MatchString(key) && len(key) < 100 {
+				if headerKeyRe.MatchString(key) && len(key) < 100 {
 					// Clean up folded headers
-					value = regexp.MustCompile(`\s*\r?\n\s*`).ReplaceAllString(value, " ")
+					value = headerFoldRe.ReplaceAllString(value, " ")
+
+					// Raw 8-bit header values are not valid UTF-8
+					if !p.options.Strict && !utf8.ValidString(value) {
+						value = decode8BitHeader(value)
+					}
 
 					if existing, exists := p.node.ParsedHeader[key]; exists {
 						if arr, isArray := existing.([]string); isArray {
@@ -261,13 +424,35 @@ func (p *MIMEParser) processNodeHeader() {
 	}
 }
 
+// decode8BitHeader keeps valid UTF-8 sequences and reads all other bytes as Windows-1252
+func decode8BitHeader(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if r == utf8.RuneError && size <= 1 {
+			if mapped, ok := windows1252[value[i]]; ok {
+				r = mapped
+			} else {
+				r = rune(value[i])
+			}
+		}
+		sb.WriteRune(r)
+		i += size
+	}
+	return sb.String()
+}
+
 // parseValueParams splits a header value into structured data
 func (p *MIMEParser) parseValueParams(headerValue string) *ValueParams {
 	data := &ValueParams{
 		Params: make(map[string]string),
 	}
 
-	parts := strings.Split(headerValue, ";")
+	// RFC 2231 parameters (name*, name*0, name*1*, ...) are collected separately
+	// and merged once all parts are known
+	extended := make(map[string]map[int]paramSegment)
+
+	parts := splitParams(headerValue)
 	for i, part := range parts {
 		part = strings.TrimSpace(part)
 		if i == 0 {
@@ -283,37 +468,144 @@ func (p *MIMEParser) parseValueParams(headerValue string) *ValueParams {
 			paramParts := strings.SplitN(part, "=", 2)
 			if len(paramParts) == 2 {
 				key := strings.ToLower(strings.TrimSpace(paramParts[0]))
-				value := strings.Trim(strings.TrimSpace(paramParts[1]), `"'`)
-
-				if regexp.MustCompile(`^[a-zA-Z0-9\-\*]`).MatchString(key) && len(key) < 100 {
-					data.Params[key] = value
+				value := strings.TrimSpace(paramParts[1])
+
+				if headerKeyRe.MatchString(key) && len(key) < 100 {
+					if matches := extendedParamRe.FindStringSubmatch(key); matches != nil {
+						name := matches[1]
+						index := 0
+						encoded := true
+						if matches[2] != "" {
+							index, _ = strconv.Atoi(matches[2])
+							encoded = matches[3] == "*"
+						}
+						if extended[name] == nil {
+							extended[name] = make(map[int]paramSegment)
+						}
+						extended[name][index] = paramSegment{
+							value:   unquoteParam(value),
+							encoded: encoded,
+						}
+					} else {
+						data.Params[key] = strings.Trim(value, `"'`)
+					}
 					data.HasParams = true
 				}
 			}
 		}
 	}
 
+	for name, segments := range extended {
+		// Without its first section the value is broken, a plain value is kept
+		if _, ok := segments[0]; !ok {
+			continue
+		}
+		data.Params[name] = decodeExtendedParam(segments)
+	}
+
 	return data
 }
 
-// parseAddresses parses email addresses from a header value
-func (p *MIMEParser) parseAddresses(value string) []*Address {
-	addresses := make([]*Address, 0)
+// paramSegment is a single section of an RFC 2231 parameter value
+type paramSegment struct {
+	value   string
+	encoded bool
+}
 
-	addrs, err := mail.ParseAddressList(value)
-	if err != nil {
-		// Fallback for malformed addresses
-		return addresses
+var extendedParamRe = regexp.MustCompile(`^([^*]+)\*(?:(\d+)(\*)?)?$`)
+
+// splitParams splits a header value on semicolons that are not inside a quoted string
+func splitParams(headerValue string) []string {
+	parts := make([]string, 0)
+	inQuotes := false
+	escaped := false
+	start := 0
+
+	for i := 0; i < len(headerValue); i++ {
+		switch c := headerValue[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes:
+			parts = append(parts, headerValue[start:i])
+			start = i + 1
+		}
 	}
 
-	for _, addr := range addrs {
-		addresses = append(addresses, &Address{
-			Name:    addr.Name,
-			Address: addr.Address,
-		})
+	return append(parts, headerValue[start:])
+}
+
+// unquoteParam removes surrounding double quotes and backslash escapes from a parameter value
+func unquoteParam(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
 	}
 
-	return addresses
+	var sb strings.Builder
+	inner := value[1 : len(value)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		sb.WriteByte(inner[i])
+	}
+	return sb.String()
+}
+
+// decodeExtendedParam joins RFC 2231 continuations and decodes the charset'language'value syntax
+func decodeExtendedParam(segments map[int]paramSegment) string {
+	charset := ""
+	raw := make([]byte, 0)
+
+	for i := 0; ; i++ {
+		segment, exists := segments[i]
+		if !exists {
+			break
+		}
+
+		value := segment.value
+		if segment.encoded {
+			if i == 0 {
+				// The first encoded section starts with charset'language'
+				if sections := strings.SplitN(value, "'", 3); len(sections) == 3 {
+					charset = sections[0]
+					value = sections[2]
+				}
+			}
+			raw = append(raw, percentDecode(value)...)
+		} else {
+			raw = append(raw, value...)
+		}
+	}
+
+	return decodeCharset(raw, charset)
+}
+
+// percentDecode decodes %XX sequences, leaving malformed sequences untouched
+func percentDecode(value string) []byte {
+	result := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]) {
+			b, _ := strconv.ParseUint(value[i+1:i+3], 16, 8)
+			result = append(result, byte(b))
+			i += 2
+			continue
+		}
+		result = append(result, value[i])
+	}
+	return result
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// parseAddresses parses email addresses from a header value
+func (p *MIMEParser) parseAddresses(value string) []*Address {
+	return parseAddressList(value)
 }
 
 // processContentType checks Content-Type value for multipart handling
@@ -329,34 +621,114 @@ func (p *MIMEParser) processContentType() {
 	}
 
 	if ct.Type == "multipart" {
-		if boundary, hasBoundary := ct.Params["boundary"]; hasBoundary {
+		// Parts nested too deep are kept as opaque bodies
+		if p.node.depth+1 >= limit(p.options.MaxDepth, DefaultMaxDepth) {
+			p.state.truncated = true
+			return
+		}
+
+		boundary, hasBoundary := ct.Params["boundary"]
+		if !hasBoundary && !p.options.Strict {
+			boundary = p.guessBoundary()
+			hasBoundary = boundary != ""
+		}
+
+		if hasBoundary {
 			p.node.Multipart = ct.Subtype
 			p.node.Boundary = boundary
 		}
 	}
 }
 
+// guessBoundary recovers the boundary of a multipart part without a boundary
+// parameter from the first line of its body that looks like a delimiter
+func (p *MIMEParser) guessBoundary() string {
+	rest := p.rfc822[p.pos:]
+	for len(rest) > 0 {
+		line := rest
+		if end := strings.IndexByte(rest, '\n'); end >= 0 {
+			line, rest = rest[:end], rest[end+1:]
+		} else {
+			rest = ""
+		}
+
+		line = strings.TrimRight(line, " \t\r")
+		if len(line) <= 2 || !strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		boundary := strings.TrimSuffix(line[2:], "--")
+		for ancestor := p.node.parentNode; ancestor != nil; ancestor = ancestor.parentNode {
+			if ancestor.Boundary == boundary {
+				return "" // the delimiter belongs to an enclosing part
+			}
+		}
+		return boundary
+	}
+
+	return ""
+}
+
 // FinalizeTree joins body arrays and removes unnecessary fields
 func (p *MIMEParser) FinalizeTree() {
 	p.finalizeNode(p.tree)
 }
 
+// normalizeLineEndings converts bare LF line endings to CRLF
+func normalizeLineEndings(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
 func (p *MIMEParser) finalizeNode(node *MIMENode) {
 	if len(node.Body) > 0 {
-		// Count lines in body
-		node.LineCount = strings.Count(string(node.Body), "\n") + 1
-
 		// Ensure proper line endings
 		bodyStr := string(node.Body)
-		bodyStr = regexp.MustCompile(`\r?\n`).ReplaceAllString(bodyStr, "\r\n")
+		bodyStr = normalizeLineEndings(bodyStr)
 		node.Body = []byte(bodyStr)
+
+		// Size and line count describe the body exactly as it is served,
+		// an unterminated last line counts as a line
 		node.Size = len(node.Body)
+		node.LineCount = strings.Count(bodyStr, "\r\n")
+		if !strings.HasSuffix(bodyStr, "\r\n") {
+			node.LineCount++
+		}
+	}
+
+	if node.hasBody && len(node.Body) == 0 {
+		node.BlankBody = true
+	}
+
+	if len(node.Epilogue) > 0 {
+		node.Epilogue = []byte(normalizeLineEndings(string(node.Epilogue)))
 	}
 
 	for _, child := range node.ChildNodes {
 		p.finalizeNode(child)
 	}
 
+	// Parts of an embedded message are served like any other part
+	if node.Message != nil {
+		p.finalizeNode(node.Message)
+	}
+
+	// A multipart part whose boundary never occurs is kept as a text part,
+	// otherwise its content would be lost as preamble
+	if node.Multipart != "" && len(node.ChildNodes) == 0 && !node.closed && !p.options.Strict {
+		node.Multipart = ""
+		node.Boundary = ""
+		node.ParsedHeader["content-type"] = &ValueParams{
+			Value:   "text/plain",
+			Type:    "text",
+			Subtype: "plain",
+			Params:  make(map[string]string),
+		}
+	}
+
+	if node.Boundary != "" && !node.closed {
+		node.Unterminated = true
+	}
+
 	// Clean up fields that aren't needed in final output
 	if len(node.ChildNodes) == 0 {
 		node.ChildNodes = nil
@@ -373,12 +745,23 @@ func (p *MIMEParser) GetResult() *MIMENode {
 
 // ParseMIME parses an RFC822 message and returns the MIME tree
 func ParseMIME(rfc822 []byte) (*MIMENode, error) {
-	parser := NewMIMEParser(rfc822)
+	return ParseMIMEWithOptions(rfc822, nil)
+}
+
+// ParseMIMEWithOptions parses an RFC822 message with custom parser limits.
+// Content beyond the limits is left unparsed and the root node is marked as Truncated
+func ParseMIMEWithOptions(rfc822 []byte, options *ParseOptions) (*MIMENode, error) {
+	parser := NewMIMEParserWithOptions(rfc822, options)
 	err := parser.Parse()
 	if err != nil {
 		return nil, err
 	}
 
 	parser.FinalizeTree()
-	return parser.GetResult(), nil
+
+	result := parser.GetResult()
+	if result != nil && parser.state.truncated {
+		result.Truncated = true
+	}
+	return result, nil
 }
@@ -1,6 +1,9 @@
 package indexer
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -250,6 +253,74 @@ func TestAddressParsing(t *testing.T) {
 	}
 }
 
+func TestGroupAddressParsing(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		expected []*Address
+	}{
+		{
+			name:     "Empty group",
+			header:   "To: undisclosed-recipients:;",
+			expected: []*Address{{Name: "undisclosed-recipients", IsGroup: true, Group: []*Address{}}},
+		},
+		{
+			name:   "Group with members",
+			header: "To: Team: a@example.com, Bob <b@example.com>;",
+			expected: []*Address{{Name: "Team", IsGroup: true, Group: []*Address{
+				{Address: "a@example.com"},
+				{Name: "Bob", Address: "b@example.com"},
+			}}},
+		},
+		{
+			name:   "Mailboxes around a group",
+			header: `To: "Doe, John" <john@example.com>, "Team: Sales": c@example.com;, jane@example.com`,
+			expected: []*Address{
+				{Name: "Doe, John", Address: "john@example.com"},
+				{Name: "Team: Sales", IsGroup: true, Group: []*Address{{Address: "c@example.com"}}},
+				{Address: "jane@example.com"},
+			},
+		},
+		{
+			name:   "Malformed address",
+			header: "To: John Doe (Sales) <john@example>, Jane [Ops] <jane@example.com>",
+			expected: []*Address{
+				{Name: "John Doe", Address: "john@example"},
+				{Name: "Jane [Ops]", Address: "jane@example.com"},
+			},
+		},
+		{
+			name:   "Domain literal",
+			header: "To: a@example.com, user@[IPv6:2001:db8::1], Ops: root@[192.0.2.1];",
+			expected: []*Address{
+				{Address: "a@example.com"},
+				{Address: "user@[IPv6:2001:db8::1]"},
+				{Name: "Ops", IsGroup: true, Group: []*Address{{Address: "root@[192.0.2.1]"}}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tc.header + "\nSubject: Test\n\nBody"))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			addresses, ok := tree.ParsedHeader["to"].([]*Address)
+			if !ok {
+				t.Fatalf("Expected address slice, got %T", tree.ParsedHeader["to"])
+			}
+
+			if !reflect.DeepEqual(addresses, tc.expected) {
+				expected, _ := json.Marshal(tc.expected)
+				got, _ := json.Marshal(addresses)
+				t.Errorf("Expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
 func TestHeaderFolding(t *testing.T) {
 	email := `From: sender@example.com
 To: recipient@example.com
@@ -364,6 +435,57 @@ func TestValueParamsParser(t *testing.T) {
 				HasParams: true,
 			},
 		},
+		{
+			name:  "Quoted parameter with semicolon",
+			input: `attachment; filename="report; final.pdf"`,
+			expected: ValueParams{
+				Value:     "attachment",
+				Type:      "attachment",
+				Params:    map[string]string{"filename": "report; final.pdf"},
+				HasParams: true,
+			},
+		},
+		{
+			name:  "RFC 2231 extended value",
+			input: "attachment; filename*=UTF-8''%E2%82%AC%20rates.txt",
+			expected: ValueParams{
+				Value:     "attachment",
+				Type:      "attachment",
+				Params:    map[string]string{"filename": "€ rates.txt"},
+				HasParams: true,
+			},
+		},
+		{
+			name:  "RFC 2231 continuations",
+			input: `application/pdf; name*0="very long "; name*1="file name.pdf"`,
+			expected: ValueParams{
+				Value:     "application/pdf",
+				Type:      "application",
+				Subtype:   "pdf",
+				Params:    map[string]string{"name": "very long file name.pdf"},
+				HasParams: true,
+			},
+		},
+		{
+			name:  "RFC 2231 encoded continuations",
+			input: `attachment; filename*0*=iso-8859-1'de'Gr%FC%DFe; filename*1=" und "; filename*2*=%C4rger.txt`,
+			expected: ValueParams{
+				Value:     "attachment",
+				Type:      "attachment",
+				Params:    map[string]string{"filename": "Grüße und Ärger.txt"},
+				HasParams: true,
+			},
+		},
+		{
+			name:  "RFC 2231 continuation without first section",
+			input: `attachment; filename="report.pdf"; filename*1="x"`,
+			expected: ValueParams{
+				Value:     "attachment",
+				Type:      "attachment",
+				Params:    map[string]string{"filename": "report.pdf"},
+				HasParams: true,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -395,6 +517,338 @@ func TestValueParamsParser(t *testing.T) {
 	}
 }
 
+func TestRFC2231AttachmentNames(t *testing.T) {
+	email := `From: sender@example.com
+To: recipient@example.com
+Subject: RFC 2231 Test
+Content-Type: multipart/mixed; boundary="rfc2231"
+
+--rfc2231
+Content-Type: text/plain
+
+See attachment.
+
+--rfc2231
+Content-Type: application/pdf;
+ name*0*=UTF-8''%D0%9E%D1%82%D1%87%D0%B5%D1%82;
+ name*1=".pdf"
+Content-Disposition: attachment;
+ filename*=UTF-8''%D0%9E%D1%82%D1%87%D0%B5%D1%82.pdf
+
+PDF content
+
+--rfc2231--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if len(tree.ChildNodes) != 2 {
+		t.Fatalf("Expected 2 child nodes, got %d", len(tree.ChildNodes))
+	}
+	attachment := tree.ChildNodes[1]
+
+	ct, ok := attachment.ParsedHeader["content-type"].(*ValueParams)
+	if !ok {
+		t.Fatalf("Expected ValueParams content-type, got %T", attachment.ParsedHeader["content-type"])
+	}
+	if name := ct.Params["name"]; name != "Отчет.pdf" {
+		t.Errorf("Expected name 'Отчет.pdf', got '%s'", name)
+	}
+	for key := range ct.Params {
+		if strings.Contains(key, "*") {
+			t.Errorf("Unexpected raw continuation parameter %s", key)
+		}
+	}
+
+	disposition, ok := attachment.ParsedHeader["content-disposition"].(*ValueParams)
+	if !ok {
+		t.Fatalf("Expected ValueParams content-disposition, got %T", attachment.ParsedHeader["content-disposition"])
+	}
+	if filename := disposition.Params["filename"]; filename != "Отчет.pdf" {
+		t.Errorf("Expected filename 'Отчет.pdf', got '%s'", filename)
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	// Every level opens a new multipart with its own boundary
+	var nested strings.Builder
+	nested.WriteString("Subject: Nested\n")
+	for i := 0; i < 200; i++ {
+		nested.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"b%d\"\n\n--b%d\n", i, i))
+	}
+	nested.WriteString("Content-Type: text/plain\n\nDeep\n")
+
+	var parts strings.Builder
+	parts.WriteString("Content-Type: multipart/mixed; boundary=\"b\"\n\n")
+	for i := 0; i < 20; i++ {
+		parts.WriteString(fmt.Sprintf("--b\nContent-Type: text/plain\n\nPart %d\n", i))
+	}
+	parts.WriteString("--b--\n")
+
+	var headers strings.Builder
+	for i := 0; i < 10; i++ {
+		headers.WriteString(fmt.Sprintf("X-Header-%d: %s\n", i, strings.Repeat("x", 50)))
+	}
+	headers.WriteString("\nBody")
+
+	testCases := []struct {
+		name      string
+		email     string
+		options   *ParseOptions
+		truncated bool
+		check     func(t *testing.T, tree *MIMENode)
+	}{
+		{
+			name:      "Nesting depth",
+			email:     nested.String(),
+			options:   &ParseOptions{MaxDepth: 10},
+			truncated: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				depth := 0
+				for node := tree; len(node.ChildNodes) > 0; node = node.ChildNodes[0] {
+					depth++
+				}
+				if depth != 9 {
+					t.Errorf("Expected nesting to stop at depth 9, got %d", depth)
+				}
+			},
+		},
+		{
+			name:      "Default nesting depth",
+			email:     nested.String(),
+			truncated: true,
+		},
+		{
+			name:      "Part count",
+			email:     parts.String(),
+			options:   &ParseOptions{MaxParts: 5},
+			truncated: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 4 {
+					t.Errorf("Expected 4 child parts, got %d", len(tree.ChildNodes))
+				}
+			},
+		},
+		{
+			name:      "Header count",
+			email:     headers.String(),
+			options:   &ParseOptions{MaxHeaders: 3},
+			truncated: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.Header) != 3 || string(tree.Body) != "Body" {
+					t.Errorf("Expected 3 headers and the body, got %d headers and %q", len(tree.Header), tree.Body)
+				}
+			},
+		},
+		{
+			name:      "Header size",
+			email:     headers.String(),
+			options:   &ParseOptions{MaxHeaderSize: 200},
+			truncated: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.Header) != 3 {
+					t.Errorf("Expected 3 headers within 200 bytes, got %d", len(tree.Header))
+				}
+			},
+		},
+		{
+			name:      "Header size with folded header",
+			email:     "Subject: Kept\r\nX-Large: " + strings.Repeat("x", 200) + "\r\n continued\r\nX-Small: y\r\n\r\nBody",
+			options:   &ParseOptions{MaxHeaderSize: 100},
+			truncated: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				// The continuation of the dropped header must not fold into the kept one
+				if len(tree.Header) != 1 || tree.ParsedHeader["subject"] != "Kept" {
+					t.Errorf("Expected only the subject header, got %q", tree.Header)
+				}
+				if string(tree.Body) != "Body" {
+					t.Errorf("Expected the body, got %q", tree.Body)
+				}
+			},
+		},
+		{
+			name:  "Within limits",
+			email: parts.String(),
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 20 {
+					t.Errorf("Expected 20 child parts, got %d", len(tree.ChildNodes))
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := ParseMIMEWithOptions([]byte(tc.email), tc.options)
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			if tree.Truncated != tc.truncated {
+				t.Errorf("Expected truncated %v, got %v", tc.truncated, tree.Truncated)
+			}
+			if tc.check != nil {
+				tc.check(t, tree)
+			}
+		})
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	testCases := []struct {
+		name   string
+		email  string
+		strict bool
+		check  func(t *testing.T, tree *MIMENode)
+	}{
+		{
+			name:  "Missing end boundary",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nHello\r\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 1 || !strings.HasPrefix(string(tree.ChildNodes[0].Body), "Hello") {
+					t.Errorf("Expected the unterminated part, got %d children", len(tree.ChildNodes))
+				}
+			},
+		},
+		{
+			name: "Missing end boundary after forwarded message",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nSee below\r\n" +
+				"--b\r\nContent-Type: message/rfc822\r\n\r\nSubject: Forwarded\r\nFrom: a@example.com\r\n\r\nOriginal\r\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 2 || tree.ChildNodes[1].Message == nil {
+					t.Fatalf("Expected the forwarded message to be parsed")
+				}
+				structure := SerializeBodyStructure(CreateBodyStructure(tree, nil))
+				if !strings.Contains(structure, `(NIL "Forwarded" `) || !strings.Contains(structure, `) ("text" "plain" NIL NIL NIL "7bit" 10 1 `) {
+					t.Errorf("Expected envelope and body of the forwarded message, got %s", structure)
+				}
+			},
+		},
+		{
+			name: "Nested multipart without end boundary",
+			email: "Content-Type: multipart/mixed; boundary=a\r\n\r\n--a\r\n" +
+				"Content-Type: multipart/alternative; boundary=b\r\n\r\n--b\r\n\r\nFirst\r\n" +
+				"--a\r\n\r\nSecond\r\n--a--\r\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 2 || string(tree.ChildNodes[1].Body) != "Second" {
+					t.Errorf("Expected the second part to be a sibling, got %d children", len(tree.ChildNodes))
+				}
+				if first := tree.ChildNodes[0].ChildNodes; len(first) != 1 || string(first[0].Body) != "First" {
+					t.Errorf("Expected the nested part to end at the outer boundary")
+				}
+			},
+		},
+		{
+			name: "Nested multipart without end boundary in strict mode",
+			email: "Content-Type: multipart/mixed; boundary=a\r\n\r\n--a\r\n" +
+				"Content-Type: multipart/alternative; boundary=b\r\n\r\n--b\r\n\r\nFirst\r\n" +
+				"--a\r\n\r\nSecond\r\n--a--\r\n",
+			strict: true,
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 1 {
+					t.Errorf("Expected the outer boundary to be swallowed, got %d children", len(tree.ChildNodes))
+				}
+			},
+		},
+		{
+			name:  "Boundary with transport padding",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b  \r\nContent-Type: text/plain\r\n\r\nHi\r\n--b--\t\r\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 1 || string(tree.ChildNodes[0].Body) != "Hi" {
+					t.Errorf("Expected one part with body Hi, got %d children", len(tree.ChildNodes))
+				}
+			},
+		},
+		{
+			name:  "Missing boundary parameter",
+			email: "Content-Type: multipart/mixed\r\n\r\nPreamble\r\n--guess\r\n\r\nHi\r\n--guess--\r\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if tree.Boundary != "guess" || len(tree.ChildNodes) != 1 {
+					t.Errorf("Expected guessed boundary with one part, got %q and %d children", tree.Boundary, len(tree.ChildNodes))
+				}
+			},
+		},
+		{
+			name:  "Boundary never occurs",
+			email: "Content-Type: multipart/mixed; boundary=missing\r\n\r\nPlain text sent as multipart",
+			check: func(t *testing.T, tree *MIMENode) {
+				if text := ExtractText(tree); text != "Plain text sent as multipart" {
+					t.Errorf("Expected the body to be kept as text, got %q", text)
+				}
+			},
+		},
+		{
+			name:  "Header without body",
+			email: "Subject: Only headers\r\nFrom: sender@example.com",
+			check: func(t *testing.T, tree *MIMENode) {
+				if tree.ParsedHeader["subject"] != "Only headers" {
+					t.Errorf("Expected parsed subject, got %v", tree.ParsedHeader["subject"])
+				}
+			},
+		},
+		{
+			name:  "8-bit headers",
+			email: "Subject: \x93Caf\xe9\x94 \xc3\xa0 la carte\r\nFrom: J\xfcrgen <j@example.com>\r\n\r\nHi",
+			check: func(t *testing.T, tree *MIMENode) {
+				if tree.ParsedHeader["subject"] != "“Café” à la carte" {
+					t.Errorf("Expected Windows-1252 bytes to be decoded, got %q", tree.ParsedHeader["subject"])
+				}
+				if from := tree.ParsedHeader["from"].([]*Address); from[0].Name != "Jürgen" {
+					t.Errorf("Expected name Jürgen, got %q", from[0].Name)
+				}
+			},
+		},
+		{
+			name:  "Mixed line endings",
+			email: "Subject: x\nContent-Type: multipart/mixed; boundary=b\r\n\r\n--b\nContent-Type: text/plain\r\n\r\nHi\r\n--b--\n",
+			check: func(t *testing.T, tree *MIMENode) {
+				if len(tree.ChildNodes) != 1 || string(tree.ChildNodes[0].Body) != "Hi" {
+					t.Errorf("Expected one part with body Hi, got %d children", len(tree.ChildNodes))
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := ParseMIMEWithOptions([]byte(tc.email), &ParseOptions{Strict: tc.strict})
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+			tc.check(t, tree)
+		})
+	}
+}
+
+func FuzzParseMIME(f *testing.F) {
+	f.Add([]byte("From: a@example.com\r\nSubject: Test\r\n\r\nBody"))
+	f.Add([]byte("Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\n\r\nA\r\n--b--\r\n"))
+	f.Add([]byte("Content-Type: multipart/mixed\r\n\r\n--b\r\nContent-Type: message/rfc822\r\n\r\nSubject: x\r\n\r\ny\r\n--b--"))
+	f.Add([]byte("Content-Type: text/plain; name*0*=utf-8''a%20; name*1=b\r\nTo: g:;\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tree, err := ParseMIMEWithOptions(data, &ParseOptions{MaxDepth: 10, MaxParts: 100})
+		if err != nil || tree == nil {
+			return
+		}
+
+		// Derived data must be computable for any tree the parser returns
+		CreateBodyStructure(tree, nil)
+		ExtractText(tree)
+		GetAttachments(tree)
+
+		// Complete messages with CRLF line breaks serialize to their source
+		bare := strings.ReplaceAll(string(data), "\r\n", "")
+		if !tree.Truncated && !strings.ContainsAny(bare, "\r\n") {
+			if serialized := Serialize(tree); string(serialized) != string(data) {
+				t.Errorf("Expected %q, got %q", data, serialized)
+			}
+		}
+	})
+}
+
 func TestParserPerformance(t *testing.T) {
 	// Create a moderately complex email
 	email := `From: sender@example.com
@@ -438,7 +892,50 @@ Content-Type: text/html; charset=utf-8
 	}
 }
 
+// largeBodyEmail creates a message with a body of the given number of lines
+func largeBodyEmail(lines int) []byte {
+	return []byte("From: sender@example.com\r\nSubject: Large\r\n\r\n" +
+		strings.Repeat("A line of body text that is about as long as a typical one\r\n", lines))
+}
+
+func TestParserPerformanceLinear(t *testing.T) {
+	// The fastest of a few runs keeps scheduling noise out of the comparison
+	parseTime := func(email []byte) time.Duration {
+		fastest := time.Duration(0)
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			if _, err := ParseMIME(email); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if elapsed := time.Since(start); fastest == 0 || elapsed < fastest {
+				fastest = elapsed
+			}
+		}
+		return fastest
+	}
+
+	small := parseTime(largeBodyEmail(10000))
+	large := parseTime(largeBodyEmail(40000))
+	t.Logf("Parsed 10000 lines in %v, 40000 lines in %v", small, large)
+
+	// Four times the input takes about four times as long, quadratic parsing takes sixteen
+	if large > 10*small && large > 50*time.Millisecond {
+		t.Errorf("Parse time grows faster than the input: %v for 10000 lines, %v for 40000 lines", small, large)
+	}
+}
+
 // Benchmark tests
+func BenchmarkLargeBodyParsing(b *testing.B) {
+	email := largeBodyEmail(40000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseMIME(email); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkSimpleEmailParsing(b *testing.B) {
 	email := []byte(`From: sender@example.com
 To: recipient@example.com
@@ -0,0 +1,75 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Attribution lines introducing a quoted reply, in the most common client languages
+	attributionStartRe = regexp.MustCompile(`^(On|Am|Le|El|Il|Op|Den|W dniu)\s`)
+	attributionEndRe   = regexp.MustCompile(`(wrote|schrieb|a écrit|escribió|ha scritto|schreef|skrev|napisał)(\s.*)?:$`)
+
+	// Separators after which mail clients append the original message
+	originalMessageRe = regexp.MustCompile(`(?i)^-{2,}\s*(original message|ursprüngliche nachricht|message d'origine|mensaje original)\s*-{2,}$`)
+	outlookRuleRe     = regexp.MustCompile(`^_{20,}$`)
+	outlookHeaderRe   = regexp.MustCompile(`(?i)^(from|von|de):\s`)
+
+	// Footers added by mobile clients
+	mobileFooterRe = regexp.MustCompile(`(?i)^(sent from my \w+|sent from (outlook|mail) for \w+|get outlook for \w+)`)
+)
+
+// ExtractPrimaryText returns the text the sender actually wrote: the message
+// text without quoted replies, forwarded originals and signatures
+func ExtractPrimaryText(tree *MIMENode) string {
+	return StripQuotedContent(ExtractText(tree))
+}
+
+// StripQuotedContent removes quoted reply blocks, their attribution lines,
+// appended original messages and signatures from plain text
+func StripQuotedContent(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	kept := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Signature delimiter, everything after it is the signature
+		if line == "-- " || line == "--" {
+			break
+		}
+
+		// Outlook style original message, everything after it is quoted
+		if originalMessageRe.MatchString(trimmed) {
+			break
+		}
+		if outlookRuleRe.MatchString(trimmed) && i+1 < len(lines) && outlookHeaderRe.MatchString(strings.TrimSpace(lines[i+1])) {
+			break
+		}
+
+		if strings.HasPrefix(trimmed, ">") || mobileFooterRe.MatchString(trimmed) {
+			continue
+		}
+
+		// Attribution lines may be wrapped over two lines by the sending client
+		if attributionStartRe.MatchString(trimmed) {
+			if attributionEndRe.MatchString(trimmed) {
+				continue
+			}
+			if i+1 < len(lines) && attributionEndRe.MatchString(strings.TrimSpace(lines[i+1])) {
+				i++
+				continue
+			}
+		}
+
+		// Removed blocks should not leave runs of blank lines behind
+		if trimmed == "" && len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
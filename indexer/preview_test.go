@@ -0,0 +1,89 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestStripQuotedContent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "quoted reply with attribution",
+			input:    "Thanks, works for me.\n\nOn Mon, 23 Nov 2024 at 10:00, Jane <jane@example.com> wrote:\n> Does Friday work?\n> Jane",
+			expected: "Thanks, works for me.",
+		},
+		{
+			name:     "wrapped attribution line",
+			input:    "Sure.\n\nOn Mon, 23 Nov 2024 at 10:00, Jane Smith <jane.smith@\nexample.com> wrote:\n> Question?",
+			expected: "Sure.",
+		},
+		{
+			name:     "german attribution",
+			input:    "Danke!\n\nAm 23.11.2024 um 10:00 schrieb Jane:\n> Frage?",
+			expected: "Danke!",
+		},
+		{
+			name:     "interleaved reply",
+			input:    "> First question?\nFirst answer.\n\n> Second question?\nSecond answer.",
+			expected: "First answer.\n\nSecond answer.",
+		},
+		{
+			name:     "signature",
+			input:    "Body text\n\n-- \nJohn Doe\nACME Corp",
+			expected: "Body text",
+		},
+		{
+			name:     "outlook original message",
+			input:    "See below.\n\n-----Original Message-----\nFrom: Jane\nSent: Monday\n\nOld text",
+			expected: "See below.",
+		},
+		{
+			name:     "outlook rule and header block",
+			input:    "Agreed.\n\n________________________________\nFrom: Jane Smith <jane@example.com>\nSent: Monday",
+			expected: "Agreed.",
+		},
+		{
+			name:     "mobile footer",
+			input:    "On my way\n\nSent from my iPhone",
+			expected: "On my way",
+		},
+		{
+			name:     "sentence starting with On is kept",
+			input:    "On Monday we ship the release.\nPlease review.",
+			expected: "On Monday we ship the release.\nPlease review.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := StripQuotedContent(tc.input); result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractPrimaryText(t *testing.T) {
+	email := `From: sender@example.com
+Subject: Re: Report
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/html; charset=utf-8
+
+<p>Looks great.</p><blockquote><p>Please review the report.</p></blockquote>
+
+--alt--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if text := ExtractPrimaryText(tree); text != "Looks great." {
+		t.Errorf("Expected 'Looks great.', got %q", text)
+	}
+}
@@ -0,0 +1,299 @@
+package indexer
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SanitizeOptions contains configuration options for HTML sanitization
+type SanitizeOptions struct {
+	AllowExternalResources bool // Keep remote images and stylesheet URLs instead of neutralizing them
+}
+
+// sanitizeAllowedTags lists the elements that are kept, everything else is
+// dropped while keeping its content
+var sanitizeAllowedTags = map[string]bool{
+	"a": true, "abbr": true, "address": true, "b": true, "big": true, "blockquote": true,
+	"br": true, "caption": true, "center": true, "cite": true, "code": true, "col": true,
+	"colgroup": true, "dd": true, "del": true, "dfn": true, "div": true, "dl": true,
+	"dt": true, "em": true, "font": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "hr": true, "i": true, "img": true, "ins": true, "kbd": true,
+	"li": true, "ol": true, "p": true, "pre": true, "q": true, "s": true, "samp": true,
+	"small": true, "span": true, "strike": true, "strong": true, "sub": true, "sup": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true, "tt": true, "u": true, "ul": true, "var": true,
+}
+
+// sanitizeDroppedTags are removed together with their content
+var sanitizeDroppedTags = map[string]bool{
+	"script": true, "style": true, "title": true, "iframe": true,
+	"frame": true, "frameset": true, "object": true, "embed": true, "applet": true,
+	"template": true, "svg": true, "math": true, "textarea": true, "select": true,
+	"noscript": true, "xmp": true,
+}
+
+// sanitizeVoidTags have no closing tag
+var sanitizeVoidTags = map[string]bool{
+	"br": true, "col": true, "hr": true, "img": true,
+}
+
+// sanitizeAllowedAttributes lists attributes kept on any allowed element
+var sanitizeAllowedAttributes = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true, "cellpadding": true,
+	"cellspacing": true, "color": true, "colspan": true, "dir": true, "face": true,
+	"height": true, "lang": true, "rowspan": true, "size": true, "span": true,
+	"start": true, "style": true, "title": true, "type": true, "valign": true,
+	"width": true, "href": true, "src": true, "cite": true,
+}
+
+// SanitizeHTML removes active content from an HTML document so it can be
+// rendered by a webmail client. Only allowlisted tags and attributes are kept,
+// scripts and event handlers are stripped, unsafe URLs are removed and, unless
+// allowed by options, external resources are neutralized
+func SanitizeHTML(htmlContent string, options *SanitizeOptions) string {
+	if options == nil {
+		options = &SanitizeOptions{}
+	}
+
+	var sb strings.Builder
+	open := make([]string, 0)
+	dropDepth := 0
+	inHead := false
+
+	for _, token := range tokenizeHTML(htmlContent) {
+		// The head is dropped, it ends at "</head>" or implicitly at the first
+		// content that cannot be part of it
+		if inHead {
+			switch {
+			case token.Type == htmlTextToken && strings.TrimSpace(token.Data) == "":
+				continue
+			case token.Type == htmlEndTagToken && token.Data == "head":
+				inHead = false
+				continue
+			case token.Type != htmlTextToken && htmlHeadElements[token.Data]:
+			case dropDepth > 0:
+			default:
+				inHead = false
+			}
+		}
+
+		switch token.Type {
+		case htmlTextToken:
+			if dropDepth == 0 && !inHead {
+				sb.WriteString(html.EscapeString(token.Data))
+			}
+
+		case htmlStartTagToken:
+			if token.Data == "head" {
+				inHead = !token.SelfClosing
+				continue
+			}
+			if sanitizeDroppedTags[token.Data] {
+				if !token.SelfClosing {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 || inHead || !sanitizeAllowedTags[token.Data] {
+				continue
+			}
+
+			sb.WriteString("<" + token.Data)
+			for _, attr := range sanitizeAttributes(&token, options) {
+				sb.WriteString(" " + attr.Name + `="` + html.EscapeString(attr.Value) + `"`)
+			}
+			sb.WriteString(">")
+
+			if !sanitizeVoidTags[token.Data] && !token.SelfClosing {
+				open = append(open, token.Data)
+			}
+
+		case htmlEndTagToken:
+			if sanitizeDroppedTags[token.Data] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 || !sanitizeAllowedTags[token.Data] {
+				continue
+			}
+
+			// Close everything up to the matching open element, stray end tags are ignored
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						sb.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + open[i] + ">")
+	}
+
+	return sb.String()
+}
+
+// sanitizeAttributes returns the safe attributes of a start tag
+func sanitizeAttributes(token *htmlToken, options *SanitizeOptions) []htmlAttribute {
+	attrs := make([]htmlAttribute, 0, len(token.Attrs))
+
+	for _, attr := range token.Attrs {
+		if !sanitizeAllowedAttributes[attr.Name] {
+			continue
+		}
+
+		switch attr.Name {
+		case "href", "cite":
+			if !isSafeURL(attr.Value, false) {
+				continue
+			}
+		case "src":
+			if token.Data != "img" || !isSafeURL(attr.Value, true) {
+				continue
+			}
+			if isExternalURL(attr.Value) && !options.AllowExternalResources {
+				// Keep the address so clients can offer to load remote content
+				attrs = append(attrs, htmlAttribute{Name: "data-external-src", Value: attr.Value})
+				continue
+			}
+		case "style":
+			if !isSafeStyle(attr.Value, options) {
+				continue
+			}
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	if token.Data == "a" {
+		for _, attr := range attrs {
+			if attr.Name == "href" && isExternalURL(attr.Value) {
+				attrs = append(attrs,
+					htmlAttribute{Name: "target", Value: "_blank"},
+					htmlAttribute{Name: "rel", Value: "noopener noreferrer"})
+				break
+			}
+		}
+	}
+
+	return attrs
+}
+
+// isSafeURL checks the scheme of a URL attribute. Relative URLs are allowed,
+// data URLs only for inline images
+func isSafeURL(value string, image bool) bool {
+	lower := normalizeURL(value)
+
+	colon := strings.IndexByte(lower, ':')
+	if colon < 0 || strings.ContainsAny(lower[:colon], "/?#") {
+		return true
+	}
+
+	switch lower[:colon] {
+	case "http", "https", "mailto", "cid":
+		return true
+	case "data":
+		return image && (strings.HasPrefix(lower, "data:image/png") ||
+			strings.HasPrefix(lower, "data:image/gif") ||
+			strings.HasPrefix(lower, "data:image/jpeg"))
+	default:
+		return false
+	}
+}
+
+// isExternalURL checks if a URL points to a remote resource
+func isExternalURL(value string) bool {
+	lower := normalizeURL(value)
+	return strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:") || strings.HasPrefix(lower, "//")
+}
+
+// normalizeURL lowercases a URL the way browsers read it: whitespace and
+// control characters are ignored and a backslash counts as a slash
+func normalizeURL(value string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		switch {
+		case r <= ' ' || r == 0x7f:
+			return -1
+		case r == '\\':
+			return '/'
+		}
+		return r
+	}, value))
+}
+
+// isSafeStyle checks an inline style attribute for script and remote loading constructs
+func isSafeStyle(value string, options *SanitizeOptions) bool {
+	// Escapes, comments and whitespace could hide the constructs from the check
+	normalized := strings.Join(strings.Fields(strings.ToLower(unescapeCSS(value))), "")
+	for _, pattern := range []string{"expression", "javascript:", "vbscript:", "behavior", "-moz-binding", "@import"} {
+		if strings.Contains(normalized, pattern) {
+			return false
+		}
+	}
+	if options.AllowExternalResources {
+		return true
+	}
+
+	// Functions that load images, image-set() and image() also take plain strings
+	for _, pattern := range []string{"url(", "image-set(", "image(", "cross-fade(", "element(", "src("} {
+		if strings.Contains(normalized, pattern) {
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeCSS removes comments and resolves backslash escapes (CSS Syntax Level 3),
+// e.g. "\75rl(" is "url("
+func unescapeCSS(value string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '/' && i+1 < len(value) && value[i+1] == '*':
+			end := strings.Index(value[i+2:], "*/")
+			if end < 0 {
+				return sb.String()
+			}
+			i += end + 3
+		case c == '\\' && i+1 < len(value):
+			j := i + 1
+			for j < len(value) && j < i+7 && isHex(value[j]) {
+				j++
+			}
+			if j == i+1 {
+				// Escaped newlines are removed, other characters stand for themselves
+				if value[j] != '\n' {
+					sb.WriteByte(value[j])
+				}
+				i = j
+				continue
+			}
+
+			code, _ := strconv.ParseUint(value[i+1:j], 16, 32)
+			if code == 0 || code > unicode.MaxRune || (code >= 0xd800 && code <= 0xdfff) {
+				code = unicode.ReplacementChar
+			}
+			sb.WriteRune(rune(code))
+
+			// A single whitespace character ends the escape
+			if j < len(value) && (value[j] == ' ' || value[j] == '\t' || value[j] == '\n') {
+				j++
+			}
+			i = j - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
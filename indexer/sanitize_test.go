@@ -0,0 +1,107 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "allowed markup kept",
+			input:    `<p align="center">Hello <b>World</b></p>`,
+			expected: `<p align="center">Hello <b>World</b></p>`,
+		},
+		{
+			name:     "scripts removed with content",
+			input:    `<p>Hi</p><script>alert("x")</script><SCRIPT src="x.js"></SCRIPT>`,
+			expected: `<p>Hi</p>`,
+		},
+		{
+			name:     "document wrapper and styles dropped",
+			input:    `<html><head><style>body{}</style></head><body><div>Text</div></body></html>`,
+			expected: `<div>Text</div>`,
+		},
+		{
+			name:     "event handlers stripped",
+			input:    `<div onclick="steal()" onmouseover=x>Click</div>`,
+			expected: `<div>Click</div>`,
+		},
+		{
+			name:     "javascript links removed",
+			input:    `<a href=" java&#x09;script:alert(1)">x</a><a href="vbscript:msgbox">y</a>`,
+			expected: `<a>x</a><a>y</a>`,
+		},
+		{
+			name:     "external links open safely",
+			input:    `<a href="https://example.com/?a=1&amp;b=2">site</a> <a href="mailto:a@example.com">mail</a>`,
+			expected: `<a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener noreferrer">site</a> <a href="mailto:a@example.com">mail</a>`,
+		},
+		{
+			name:     "remote images neutralized",
+			input:    `<img src="https://tracker.example.com/pixel.gif" width="1"><img src="cid:logo@example.com" alt="logo">`,
+			expected: `<img data-external-src="https://tracker.example.com/pixel.gif" width="1"><img src="cid:logo@example.com" alt="logo">`,
+		},
+		{
+			name:     "obfuscated remote images neutralized",
+			input:    `<img src="ht&#9;tp://evil.example/p.png"><img src="&#1;https://evil.example/p.png"><img src="/\evil.example/p.png">`,
+			expected: "<img data-external-src=\"ht\ttp://evil.example/p.png\"><img data-external-src=\"\x01https://evil.example/p.png\"><img data-external-src=\"/\\evil.example/p.png\">",
+		},
+		{
+			name:     "data urls only for images",
+			input:    `<img src="data:image/png;base64,AAAA"><a href="data:text/html;base64,PHNjcmlwdD4=">x</a><img src="data:text/html,x">`,
+			expected: `<img src="data:image/png;base64,AAAA"><a>x</a><img>`,
+		},
+		{
+			name:     "dangerous styles removed",
+			input:    `<p style="color: red">a</p><p style="width: expression(alert(1))">b</p><p style="background: url(https://example.com/x)">c</p>`,
+			expected: `<p style="color: red">a</p><p>b</p><p>c</p>`,
+		},
+		{
+			name:     "escaped and obfuscated styles removed",
+			input:    `<p style="background: \75rl(https://example.com/x)">a</p><p style="background: u\rl(x)">b</p><p style="background: image-set('https://example.com/x' 1x)">c</p><p style="width: ex/**/pression(alert(1))">d</p><p style="content: '\41'">e</p>`,
+			expected: `<p>a</p><p>b</p><p>c</p><p>d</p><p style="content: &#39;\41&#39;">e</p>`,
+		},
+		{
+			name:     "head without end tag",
+			input:    `<html><head><meta charset="utf-8"><title>T</title><body><p>Visible</p></body></html>`,
+			expected: `<p>Visible</p>`,
+		},
+		{
+			name:     "forms and iframes removed",
+			input:    `<form action="https://evil.example"><input name="password"><button>Go</button></form><iframe src="https://evil.example">fallback</iframe>`,
+			expected: `Go`,
+		},
+		{
+			name:     "unbalanced markup closed",
+			input:    `<div><p>Open<b>bold</div></span>Tail`,
+			expected: `<div><p>Open<b>bold</b></p></div>Tail`,
+		},
+		{
+			name:     "text escaped",
+			input:    `1 &lt; 2 &amp; "quoted"`,
+			expected: `1 &lt; 2 &amp; &#34;quoted&#34;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := SanitizeHTML(tc.input, nil); result != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLAllowExternalResources(t *testing.T) {
+	input := `<img src="https://example.com/logo.png"><p style="background: url(https://example.com/bg.png)">x</p>`
+	expected := `<img src="https://example.com/logo.png"><p style="background: url(https://example.com/bg.png)">x</p>`
+
+	result := SanitizeHTML(input, &SanitizeOptions{AllowExternalResources: true})
+	if result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
@@ -0,0 +1,104 @@
+package indexer
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxSearchableTextLength limits the message text stored for full text search
+const MaxSearchableTextLength = 256 * 1024
+
+// SearchableContent contains the normalized message fields used for full text search
+type SearchableContent struct {
+	Subject   string   `json:"subject,omitempty"`
+	Addresses []string `json:"addresses,omitempty"` // Names, addresses and domains of all participants
+	Text      string   `json:"text,omitempty"`
+	Filenames []string `json:"filenames,omitempty"`
+}
+
+// GetSearchableContent collects the subject, the sender and recipient addresses,
+// the message text and the attachment filenames of a message for indexing
+func GetSearchableContent(tree *MIMENode) *SearchableContent {
+	content := &SearchableContent{}
+	if tree == nil {
+		return content
+	}
+
+	if subject, ok := tree.ParsedHeader["subject"].(string); ok {
+		if decoded, err := headerWordDecoder.DecodeHeader(subject); err == nil {
+			subject = decoded
+		}
+		content.Subject = normalizeSearchText(subject)
+	}
+
+	seen := make(map[string]bool)
+	addTerm := func(term string) {
+		term = normalizeSearchText(term)
+		if term != "" && !seen[term] {
+			seen[term] = true
+			content.Addresses = append(content.Addresses, term)
+		}
+	}
+	for _, key := range []string{"from", "sender", "reply-to", "to", "cc", "bcc"} {
+		addresses, _ := tree.ParsedHeader[key].([]*Address)
+		for _, addr := range flattenAddresses(addresses) {
+			addTerm(addr.Name)
+			addTerm(addr.Address)
+			if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+				addTerm(addr.Address[at+1:])
+			}
+		}
+	}
+
+	text := normalizeSearchText(ExtractText(tree))
+	if len(text) > MaxSearchableTextLength {
+		cut := MaxSearchableTextLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	content.Text = text
+
+	for _, attachment := range GetAttachments(tree) {
+		if filename := normalizeSearchText(attachment.Filename); filename != "" {
+			content.Filenames = append(content.Filenames, filename)
+		}
+	}
+
+	return content
+}
+
+// String returns all searchable fields as a single text blob
+func (content *SearchableContent) String() string {
+	parts := make([]string, 0, 4)
+	for _, part := range []string{
+		content.Subject,
+		strings.Join(content.Addresses, " "),
+		content.Text,
+		strings.Join(content.Filenames, " "),
+	} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// flattenAddresses returns the mailboxes of an address list, including group members
+func flattenAddresses(addresses []*Address) []*Address {
+	result := make([]*Address, 0, len(addresses))
+	for _, addr := range addresses {
+		if addr.IsGroup {
+			result = append(result, addr.Group...)
+		} else {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// normalizeSearchText lower cases text and collapses whitespace
+func normalizeSearchText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,80 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetSearchableContent(t *testing.T) {
+	email := `From: =?UTF-8?Q?J=C3=BCrgen?= <juergen@example.com>
+To: Team: alice@example.org, Bob <bob@example.org>;
+Subject: =?UTF-8?Q?Quartalsbericht_f=C3=BCr?= Q3
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: text/plain
+
+Hi   all,
+see the   attached REPORT.
+
+--mixed
+Content-Type: application/pdf; name="Q3 Report.pdf"
+Content-Disposition: attachment; filename="Q3 Report.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+
+--mixed--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	content := GetSearchableContent(tree)
+
+	if content.Subject != "quartalsbericht für q3" {
+		t.Errorf("Expected decoded subject, got %q", content.Subject)
+	}
+
+	expectedAddresses := []string{"jürgen", "juergen@example.com", "example.com", "alice@example.org", "example.org", "bob", "bob@example.org"}
+	if strings.Join(content.Addresses, ",") != strings.Join(expectedAddresses, ",") {
+		t.Errorf("Expected addresses %v, got %v", expectedAddresses, content.Addresses)
+	}
+
+	if content.Text != "hi all, see the attached report." {
+		t.Errorf("Expected normalized text, got %q", content.Text)
+	}
+
+	if len(content.Filenames) != 1 || content.Filenames[0] != "q3 report.pdf" {
+		t.Errorf("Expected filename q3 report.pdf, got %v", content.Filenames)
+	}
+
+	blob := content.String()
+	for _, term := range []string{"quartalsbericht", "bob@example.org", "attached report", "q3 report.pdf"} {
+		if !strings.Contains(blob, term) {
+			t.Errorf("Expected blob to contain %q, got %q", term, blob)
+		}
+	}
+}
+
+func TestGetSearchableContentSingleByteCharsets(t *testing.T) {
+	email := "From: =?iso-8859-15?q?Ren=E9_=A4?= <rene@example.com>\r\n" +
+		"To: Team: =?windows-1252?q?Jos=E9?= <jose@example.org>;\r\n" +
+		"Subject: =?windows-1252?q?caf=e9_=93menu=94?=\r\n\r\nHi"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	content := GetSearchableContent(tree)
+	if content.Subject != "café “menu”" {
+		t.Errorf("Expected decoded subject, got %q", content.Subject)
+	}
+
+	expectedAddresses := []string{"rené €", "rene@example.com", "example.com", "josé", "jose@example.org", "example.org"}
+	if strings.Join(content.Addresses, ",") != strings.Join(expectedAddresses, ",") {
+		t.Errorf("Expected addresses %v, got %v", expectedAddresses, content.Addresses)
+	}
+}
@@ -0,0 +1,480 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMIMEVerifiedKeyword is the IMAP keyword for messages with a verified S/MIME signature
+const SMIMEVerifiedKeyword = "$SMIMEVerified"
+
+// MessageSecurity describes signed or encrypted content found in a message.
+// The part fields are IMAP section numbers so clients can fetch the payload
+// and verify or decrypt it locally
+type MessageSecurity struct {
+	Type          string `json:"type"` // "smime" or "pgp"
+	Signed        bool   `json:"signed,omitempty"`
+	Encrypted     bool   `json:"encrypted,omitempty"`
+	Verified      bool   `json:"verified,omitempty"`
+	Partial       bool   `json:"partial,omitempty"` // Signed or encrypted content is nested, other parts are not covered
+	Protocol      string `json:"protocol,omitempty"`
+	SignedPart    string `json:"signedPart,omitempty"`
+	SignaturePart string `json:"signaturePart,omitempty"`
+	EncryptedPart string `json:"encryptedPart,omitempty"`
+	SignerName    string `json:"signerName,omitempty"`
+	SignerEmail   string `json:"signerEmail,omitempty"`
+	VerifyError   string `json:"verifyError,omitempty"`
+}
+
+// SecurityOptions contains configuration options for signature verification
+type SecurityOptions struct {
+	Roots       *x509.CertPool // CA bundle for S/MIME signers, signatures are not verified without it
+	CurrentTime time.Time      // Time to verify certificates at, zero means now
+}
+
+var (
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAuthEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 23}
+	oidCompressedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 9}
+	oidMessageDigest     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// pkcs7ContentInfo is the outer CMS structure
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// pkcs7SignedData is the CMS SignedData structure (RFC 5652)
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+// pkcs7SignerInfo holds the signature of a single signer
+type pkcs7SignerInfo struct {
+	Version            int
+	SignerIdentifier   asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// pkcs7IssuerAndSerial identifies a signer certificate
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+// pkcs7Attribute is a signed attribute
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// DetectSecurity checks a MIME tree for S/MIME or PGP/MIME content and verifies
+// S/MIME signatures. Returns nil for messages that are neither signed nor encrypted
+func DetectSecurity(tree *MIMENode, options *SecurityOptions) *MessageSecurity {
+	if tree == nil {
+		return nil
+	}
+	if options == nil {
+		options = &SecurityOptions{}
+	}
+
+	// The signer must be the author of the message
+	from := ""
+	if addresses, ok := tree.ParsedHeader["from"].([]*Address); ok && len(addresses) > 0 {
+		from = addresses[0].Address
+	}
+
+	return detectSecurity(tree, rootSection(tree), from, options)
+}
+
+// detectSecurity checks a node addressed by the IMAP section number path
+func detectSecurity(node *MIMENode, path string, from string, options *SecurityOptions) *MessageSecurity {
+	contentType := contentTypeOf(node)
+	protocol := strings.ToLower(contentType.Params["protocol"])
+
+	switch {
+	case strings.EqualFold(contentType.Value, "multipart/signed") && len(node.ChildNodes) >= 2:
+		security := &MessageSecurity{
+			Signed:        true,
+			Protocol:      protocol,
+			SignedPart:    sectionPath(path, 1),
+			SignaturePart: sectionPath(path, 2),
+		}
+
+		switch {
+		case isPKCS7Type(protocol, "signature"):
+			security.Type = "smime"
+			signature, err := DecodeBody(node.ChildNodes[1])
+			if err == nil {
+				err = verifySMIME(security, signature, Serialize(node.ChildNodes[0]), from, options)
+			}
+			if err != nil {
+				security.VerifyError = err.Error()
+			}
+			return security
+		case protocol == "application/pgp-signature":
+			// PGP signatures are verified by the client with the sender's public key
+			security.Type = "pgp"
+			return security
+		}
+
+	case strings.EqualFold(contentType.Value, "multipart/encrypted") && len(node.ChildNodes) >= 2:
+		if protocol == "application/pgp-encrypted" {
+			return &MessageSecurity{
+				Type:          "pgp",
+				Encrypted:     true,
+				Protocol:      protocol,
+				EncryptedPart: sectionPath(path, 2),
+			}
+		}
+
+	case isPKCS7Type(strings.ToLower(contentType.Value), "mime"):
+		security := &MessageSecurity{Type: "smime", Protocol: strings.ToLower(contentType.Value)}
+		body, err := DecodeBody(node)
+
+		smimeType := strings.ToLower(contentType.Params["smime-type"])
+		if smimeType == "" && err == nil {
+			// Older clients omit the parameter, the CMS content type tells the kind
+			smimeType = cmsContentType(body)
+		}
+
+		switch smimeType {
+		case "signed-data":
+			// Opaque signature, the signed content is embedded in the structure
+			security.Signed = true
+			security.SignaturePart = path
+			if err == nil {
+				err = verifySMIME(security, body, nil, from, options)
+			}
+			if err != nil {
+				security.VerifyError = err.Error()
+			}
+		case "enveloped-data", "authenveloped-data":
+			security.Encrypted = true
+			security.EncryptedPart = path
+		default:
+			// certs-only and compressed-data are neither signed nor encrypted
+			return nil
+		}
+		return security
+
+	case contentType.Type == "text" && strings.EqualFold(contentType.Subtype, "plain"):
+		// Inline PGP, the armored block is part of the text itself
+		switch pgpArmorType(nodeText(node)) {
+		case "MESSAGE":
+			return &MessageSecurity{Type: "pgp", Encrypted: true, EncryptedPart: path}
+		case "SIGNED MESSAGE":
+			return &MessageSecurity{Type: "pgp", Signed: true, SignedPart: path}
+		}
+	}
+
+	// Signed or encrypted content may be wrapped, e.g. in multipart/mixed by a
+	// mailing list. A nested signature does not vouch for the parts around it, so
+	// only a signature at the root marks the message as verified
+	for i, child := range node.ChildNodes {
+		if security := detectSecurity(child, sectionPath(path, i+1), from, options); security != nil {
+			security.Partial = true
+			security.Verified = false
+			return security
+		}
+	}
+
+	return nil
+}
+
+// cmsContentType returns the smime-type matching the content type of a CMS
+// ContentInfo structure, empty if it is not one
+func cmsContentType(der []byte) string {
+	// Only the OID at the start of the SEQUENCE is read, so the BER indefinite
+	// lengths some clients write are no problem
+	if len(der) < 2 || der[0] != 0x30 {
+		return ""
+	}
+	pos := 2
+	if der[1] > 0x80 {
+		pos += int(der[1] & 0x7f)
+	}
+	if pos >= len(der) {
+		return ""
+	}
+
+	var contentType asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(der[pos:], &contentType); err != nil {
+		return ""
+	}
+
+	switch {
+	case contentType.Equal(oidSignedData):
+		return "signed-data"
+	case contentType.Equal(oidEnvelopedData):
+		return "enveloped-data"
+	case contentType.Equal(oidAuthEnvelopedData):
+		return "authenveloped-data"
+	case contentType.Equal(oidCompressedData):
+		return "compressed-data"
+	}
+	return ""
+}
+
+// rootSection returns the IMAP section number of the root of a message. A single
+// part message is addressed as section 1, a multipart root has no number
+func rootSection(tree *MIMENode) string {
+	if len(tree.ChildNodes) == 0 {
+		return "1"
+	}
+	return ""
+}
+
+// sectionPath returns the IMAP section number of the n-th child of a part
+func sectionPath(parent string, n int) string {
+	if parent == "" {
+		return strconv.Itoa(n)
+	}
+	return parent + "." + strconv.Itoa(n)
+}
+
+// pgpArmorType returns the type of an ASCII armored PGP block at the start of
+// text, e.g. "MESSAGE" or "SIGNED MESSAGE"
+func pgpArmorType(text string) string {
+	text = strings.TrimLeft(text, " \t\n")
+	if !strings.HasPrefix(text, "-----BEGIN PGP ") {
+		return ""
+	}
+
+	text = text[len("-----BEGIN PGP "):]
+	if end := strings.Index(text, "-----"); end > 0 {
+		return text[:end]
+	}
+	return ""
+}
+
+// isPKCS7Type checks for application/pkcs7-<kind> and its x-pkcs7 variant
+func isPKCS7Type(contentType, kind string) bool {
+	return contentType == "application/pkcs7-"+kind || contentType == "application/x-pkcs7-"+kind
+}
+
+// verifySMIME verifies a CMS SignedData structure. content is the detached
+// signed content, nil for signatures that embed their content. The signer
+// certificate must be issued for email protection to the From address
+func verifySMIME(security *MessageSecurity, der []byte, content []byte, from string, options *SecurityOptions) error {
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		return fmt.Errorf("invalid PKCS#7 structure: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return fmt.Errorf("PKCS#7 content is not signed data")
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return fmt.Errorf("invalid PKCS#7 signed data: %v", err)
+	}
+
+	if content == nil {
+		var embedded []byte
+		if _, err := asn1.Unmarshal(signedData.ContentInfo.Content.Bytes, &embedded); err != nil {
+			return fmt.Errorf("signed data contains no content")
+		}
+		content = embedded
+	}
+
+	certificates, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid signer certificates: %v", err)
+	}
+	if len(signedData.SignerInfos) == 0 {
+		return fmt.Errorf("signed data has no signers")
+	}
+
+	signerInfo := signedData.SignerInfos[0]
+	signer := findSignerCertificate(signerInfo.SignerIdentifier, certificates)
+	if signer == nil {
+		return fmt.Errorf("signer certificate not found")
+	}
+
+	security.SignerName = signer.Subject.CommonName
+	signerMatchesFrom := false
+	for _, email := range signer.EmailAddresses {
+		if from != "" && strings.EqualFold(email, from) {
+			security.SignerEmail = email
+			signerMatchesFrom = true
+			break
+		}
+	}
+	if !signerMatchesFrom && len(signer.EmailAddresses) > 0 {
+		security.SignerEmail = signer.EmailAddresses[0]
+	}
+
+	if err := checkSignerInfo(&signerInfo, signer, content); err != nil {
+		return err
+	}
+
+	// Without a configured bundle the system roots would accept any public
+	// certificate, e.g. a TLS server certificate
+	if options.Roots == nil {
+		return fmt.Errorf("no S/MIME trust roots configured")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certificates {
+		if cert != signer {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	_, err = signer.Verify(x509.VerifyOptions{
+		Roots:         options.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   options.CurrentTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	})
+	if err != nil {
+		return fmt.Errorf("signer certificate not trusted: %v", err)
+	}
+
+	if !signerMatchesFrom {
+		return fmt.Errorf("signer certificate does not match From address %s", from)
+	}
+
+	security.Verified = true
+	return nil
+}
+
+// findSignerCertificate finds the certificate referenced by a SignerIdentifier
+func findSignerCertificate(identifier asn1.RawValue, certificates []*x509.Certificate) *x509.Certificate {
+	if identifier.Class == asn1.ClassContextSpecific && identifier.Tag == 0 {
+		// SubjectKeyIdentifier
+		for _, cert := range certificates {
+			if bytes.Equal(cert.SubjectKeyId, identifier.Bytes) {
+				return cert
+			}
+		}
+		return nil
+	}
+
+	var issuerAndSerial pkcs7IssuerAndSerial
+	if _, err := asn1.Unmarshal(identifier.FullBytes, &issuerAndSerial); err != nil {
+		return nil
+	}
+	for _, cert := range certificates {
+		if cert.SerialNumber.Cmp(issuerAndSerial.Serial) == 0 && bytes.Equal(cert.RawIssuer, issuerAndSerial.Issuer.FullBytes) {
+			return cert
+		}
+	}
+
+	return nil
+}
+
+// checkSignerInfo verifies the message digest and the signature of a signer
+func checkSignerInfo(signerInfo *pkcs7SignerInfo, signer *x509.Certificate, content []byte) error {
+	hash, err := digestHash(signerInfo.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	signed := content
+	if len(signerInfo.SignedAttributes.FullBytes) > 0 {
+		var attributes []pkcs7Attribute
+		if _, err := asn1.UnmarshalWithParams(signerInfo.SignedAttributes.FullBytes, &attributes, "tag:0"); err != nil {
+			return fmt.Errorf("invalid signed attributes: %v", err)
+		}
+
+		var digest []byte
+		for _, attribute := range attributes {
+			if attribute.Type.Equal(oidMessageDigest) {
+				asn1.Unmarshal(attribute.Values.Bytes, &digest)
+			}
+		}
+
+		h := hash.New()
+		h.Write(content)
+		if digest == nil || !bytes.Equal(h.Sum(nil), digest) {
+			return fmt.Errorf("message digest mismatch")
+		}
+
+		// Signed attributes are signed as an explicit SET, not with the implicit [0] tag
+		signed = append([]byte{0x31}, signerInfo.SignedAttributes.FullBytes[1:]...)
+	}
+
+	algorithm, err := signatureAlgorithm(signer, hash)
+	if err != nil {
+		return err
+	}
+	if err := signer.CheckSignature(algorithm, signed, signerInfo.Signature); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	return nil
+}
+
+// digestHash maps a CMS digest algorithm to a hash function
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+}
+
+// signatureAlgorithm derives the x509 signature algorithm from the signer key and digest
+func signatureAlgorithm(signer *x509.Certificate, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		switch hash {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signer key type")
+}
@@ -0,0 +1,412 @@
+package indexer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smimeSigner creates detached CMS signatures for tests
+type smimeSigner struct {
+	roots *x509.CertPool
+	cert  *x509.Certificate
+	key   *ecdsa.PrivateKey
+}
+
+func newSMIMESigner(t *testing.T, usage x509.ExtKeyUsage) *smimeSigner {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "Jane Sender"},
+		EmailAddresses: []string{"jane@example.com"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create signer certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	return &smimeSigner{roots: roots, cert: cert, key: key}
+}
+
+func (s *smimeSigner) sign(t *testing.T, content []byte) []byte {
+	digest := sha256.Sum256(content)
+	digestValue, _ := asn1.Marshal(digest[:])
+
+	attributes := []pkcs7Attribute{{
+		Type:   oidMessageDigest,
+		Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: digestValue},
+	}}
+	attributesSet, _ := asn1.MarshalWithParams(attributes, "set")
+	attributesHash := sha256.Sum256(attributesSet)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, attributesHash[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	var attributesRaw asn1.RawValue
+	asn1.Unmarshal(attributesSet, &attributesRaw)
+	identifier, _ := asn1.Marshal(pkcs7IssuerAndSerial{
+		Issuer: asn1.RawValue{FullBytes: s.cert.RawIssuer},
+		Serial: s.cert.SerialNumber,
+	})
+
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		ContentInfo:      pkcs7ContentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: s.cert.Raw},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version:            1,
+			SignerIdentifier:   asn1.RawValue{FullBytes: identifier},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attributesRaw.Bytes},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode signed data: %v", err)
+	}
+
+	contentInfo, err := asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode content info: %v", err)
+	}
+
+	return contentInfo
+}
+
+// signedEmail builds a multipart/signed message; signedPart is what gets signed,
+// sentPart is what ends up in the message
+func signedEmail(t *testing.T, signer *smimeSigner, from, signedPart, sentPart string) []byte {
+	signature := base64.StdEncoding.EncodeToString(signer.sign(t, []byte(signedPart)))
+
+	return []byte("From: " + from + "\r\n" +
+		"Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\";\r\n" +
+		" micalg=sha-256; boundary=\"signed\"\r\n" +
+		"\r\n" +
+		"--signed\r\n" +
+		sentPart + "\r\n" +
+		"--signed\r\n" +
+		"Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		signature + "\r\n" +
+		"--signed--\r\n")
+}
+
+func TestDetectSecuritySMIMESigned(t *testing.T) {
+	signer := newSMIMESigner(t, x509.ExtKeyUsageEmailProtection)
+	part := "Content-Type: text/plain; charset=utf-8\r\n\r\nSigned message body"
+
+	tests := []struct {
+		name        string
+		from        string
+		sentPart    string
+		roots       *x509.CertPool
+		verified    bool
+		verifyError string
+	}{
+		{"trusted signer", "Jane@Example.com", part, signer.roots, true, ""},
+		{"untrusted signer", "jane@example.com", part, x509.NewCertPool(), false, "signer certificate not trusted"},
+		{"no trust roots", "jane@example.com", part, nil, false, "no S/MIME trust roots configured"},
+		{"modified content", "jane@example.com", strings.Replace(part, "Signed", "Forged", 1), signer.roots, false, "message digest mismatch"},
+		{"signer is not the author", "ceo@example.com", part, signer.roots, false, "signer certificate does not match From address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME(signedEmail(t, signer, tt.from, part, tt.sentPart))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			security := DetectSecurity(tree, &SecurityOptions{Roots: tt.roots})
+			if security == nil {
+				t.Fatal("Expected security info, got nil")
+			}
+			if security.Type != "smime" || !security.Signed || security.Encrypted {
+				t.Errorf("Expected signed smime message, got %+v", security)
+			}
+			if security.Verified != tt.verified {
+				t.Errorf("Expected verified %v, got %v (%s)", tt.verified, security.Verified, security.VerifyError)
+			}
+			if !strings.HasPrefix(security.VerifyError, tt.verifyError) {
+				t.Errorf("Expected error %q, got %q", tt.verifyError, security.VerifyError)
+			}
+			if security.SignedPart != "1" || security.SignaturePart != "2" {
+				t.Errorf("Expected parts 1 and 2, got %s and %s", security.SignedPart, security.SignaturePart)
+			}
+			if security.SignerName != "Jane Sender" || security.SignerEmail != "jane@example.com" {
+				t.Errorf("Expected signer Jane Sender <jane@example.com>, got %s <%s>", security.SignerName, security.SignerEmail)
+			}
+		})
+	}
+}
+
+func TestDetectSecuritySMIMEServerCertificate(t *testing.T) {
+	signer := newSMIMESigner(t, x509.ExtKeyUsageServerAuth)
+	part := "Content-Type: text/plain; charset=utf-8\r\n\r\nSigned message body"
+
+	tree, err := ParseMIME(signedEmail(t, signer, "jane@example.com", part, part))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	// A TLS certificate from a trusted CA is not valid for signing mail
+	security := DetectSecurity(tree, &SecurityOptions{Roots: signer.roots})
+	if security == nil || security.Verified {
+		t.Errorf("Expected unverified signature, got %+v", security)
+	}
+}
+
+func TestDetectSecuritySMIMESignedPartWrapped(t *testing.T) {
+	signer := newSMIMESigner(t, x509.ExtKeyUsageEmailProtection)
+	part := "Content-Type: text/plain; charset=utf-8\r\n\r\nSigned message body"
+
+	// A correctly signed mail replayed inside a message with unsigned text
+	signed := signedEmail(t, signer, "jane@example.com", part, part)
+	signed = signed[strings.Index(string(signed), "Content-Type:"):]
+	email := "From: jane@example.com\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"mixed\"\r\n" +
+		"\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Please wire $10k to account 123\r\n" +
+		"--mixed\r\n" +
+		string(signed) +
+		"--mixed--\r\n"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	security := DetectSecurity(tree, &SecurityOptions{Roots: signer.roots})
+	if security == nil || !security.Signed || !security.Partial {
+		t.Fatalf("Expected partially signed message, got %+v", security)
+	}
+	if security.Verified {
+		t.Error("Expected nested signature not to verify the message")
+	}
+	if security.SignedPart != "2.1" || security.SignaturePart != "2.2" {
+		t.Errorf("Expected parts 2.1 and 2.2, got %s and %s", security.SignedPart, security.SignaturePart)
+	}
+}
+
+func TestDetectSecuritySMIMEEncrypted(t *testing.T) {
+	// BER encoded ContentInfo of enveloped data
+	envelopedData := "MIAGCSqGSIb3DQEHA6CAMIACAQAxggFA"
+	// DER encoded ContentInfo of compressed data
+	compressedData := "MA0GCyqGSIb3DQEJEAEJ"
+
+	tests := []struct {
+		name      string
+		smimeType string
+		body      string
+		encrypted bool
+	}{
+		{"enveloped data", "; smime-type=enveloped-data", envelopedData, true},
+		{"authenticated enveloped data", "; smime-type=authEnveloped-data", envelopedData, true},
+		{"missing smime-type", "", envelopedData, true},
+		{"certs only", "; smime-type=certs-only", envelopedData, false},
+		{"compressed data", "; smime-type=compressed-data", compressedData, false},
+		{"compressed data without smime-type", "", compressedData, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "From: jane@example.com\r\n" +
+				"Content-Type: application/pkcs7-mime" + tt.smimeType + "; name=\"smime.p7m\"\r\n" +
+				"Content-Transfer-Encoding: base64\r\n\r\n" +
+				tt.body + "\r\n"
+
+			tree, err := ParseMIME([]byte(email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			security := DetectSecurity(tree, nil)
+			if !tt.encrypted {
+				if security != nil {
+					t.Errorf("Expected nil, got %+v", security)
+				}
+				return
+			}
+			if security == nil || !security.Encrypted || security.Signed || security.Verified {
+				t.Errorf("Expected encrypted smime message, got %+v", security)
+			}
+		})
+	}
+}
+
+func TestDetectSecurityPlainMessage(t *testing.T) {
+	email := `From: jane@example.com
+Content-Type: text/plain
+
+Hello`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if security := DetectSecurity(tree, nil); security != nil {
+		t.Errorf("Expected nil, got %+v", security)
+	}
+}
+
+func TestDetectSecurityPGP(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		expected MessageSecurity
+	}{
+		{
+			name: "PGP/MIME encrypted",
+			email: `From: jane@example.com
+Content-Type: multipart/encrypted; protocol="application/pgp-encrypted"; boundary="enc"
+
+--enc
+Content-Type: application/pgp-encrypted
+
+Version: 1
+
+--enc
+Content-Type: application/octet-stream; name="encrypted.asc"
+
+-----BEGIN PGP MESSAGE-----
+
+hQEMA1234567890ABCDEF
+-----END PGP MESSAGE-----
+
+--enc--`,
+			expected: MessageSecurity{Type: "pgp", Encrypted: true, Protocol: "application/pgp-encrypted", EncryptedPart: "2"},
+		},
+		{
+			name: "PGP/MIME signed inside mixed",
+			email: `From: jane@example.com
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: multipart/signed; micalg=pgp-sha256; protocol="application/pgp-signature"; boundary="sig"
+
+--sig
+Content-Type: text/plain
+
+Signed text
+
+--sig
+Content-Type: application/pgp-signature; name="signature.asc"
+
+-----BEGIN PGP SIGNATURE-----
+
+iQEzBAEBCAAdFiEE
+-----END PGP SIGNATURE-----
+
+--sig--
+
+--mixed
+Content-Type: text/plain
+
+Mailing list footer
+
+--mixed--`,
+			expected: MessageSecurity{Type: "pgp", Signed: true, Partial: true, Protocol: "application/pgp-signature", SignedPart: "1.1", SignaturePart: "1.2"},
+		},
+		{
+			name: "inline PGP message",
+			email: `From: jane@example.com
+Content-Type: text/plain
+
+-----BEGIN PGP MESSAGE-----
+
+hQEMA1234567890ABCDEF
+-----END PGP MESSAGE-----`,
+			expected: MessageSecurity{Type: "pgp", Encrypted: true, EncryptedPart: "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			security := DetectSecurity(tree, nil)
+			if security == nil {
+				t.Fatal("Expected security info, got nil")
+			}
+			if *security != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *security)
+			}
+		})
+	}
+}
+
+func TestExtractTextSkipsPGPCiphertext(t *testing.T) {
+	email := `From: jane@example.com
+Content-Type: multipart/encrypted; protocol="application/pgp-encrypted"; boundary="enc"
+
+--enc
+Content-Type: application/pgp-encrypted
+
+Version: 1
+
+--enc
+Content-Type: text/plain
+
+-----BEGIN PGP MESSAGE-----
+
+hQEMA1234567890ABCDEF
+-----END PGP MESSAGE-----
+
+--enc--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if text := ExtractText(tree); text != "" {
+		t.Errorf("Expected no text, got %q", text)
+	}
+}
@@ -0,0 +1,70 @@
+package indexer
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Serialize rebuilds the RFC822 source of a parsed message or MIME part.
+// Header order, boundary lines, preambles and epilogues are kept, so a message
+// with CRLF line breaks is reproduced byte for byte. Line breaks are always
+// written as CRLF and content dropped by a ParseOptions limit is not restored
+func Serialize(node *MIMENode) []byte {
+	var buf bytes.Buffer
+	writeNode(&buf, node)
+	return buf.Bytes()
+}
+
+// writeNode writes the lines of a part. The line break after its last line
+// belongs to the boundary line that follows
+func writeNode(buf *bytes.Buffer, node *MIMENode) {
+	buf.WriteString(strings.Join(node.Header, "\r\n"))
+	if !node.HeaderOnly {
+		if len(node.Header) > 0 {
+			buf.WriteString("\r\n")
+		}
+		// The empty line is followed by the body, if there is one
+		if len(node.Body) > 0 || node.BlankBody {
+			buf.WriteString("\r\n")
+			buf.Write(node.Body)
+		}
+	}
+
+	if node.Boundary == "" {
+		return
+	}
+
+	for _, child := range node.ChildNodes {
+		delimiter := child.Delimiter
+		if delimiter == "" {
+			delimiter = "--" + node.Boundary
+		}
+		buf.WriteString("\r\n" + delimiter)
+		// A part without any lines is a boundary at the end of the message
+		if !child.HeaderOnly || len(child.Header) > 0 {
+			buf.WriteString("\r\n")
+		}
+		writeNode(buf, child)
+	}
+
+	// The content of an unterminated part runs to the end of its parent
+	if node.Unterminated {
+		return
+	}
+	delimiter := node.CloseDelimiter
+	if delimiter == "" {
+		delimiter = "--" + node.Boundary + "--"
+	}
+	buf.WriteString("\r\n" + delimiter)
+	buf.Write(node.Epilogue)
+}
+
+// headerBlockLength returns the offset of the body in the serialized source of a
+// node, after the header block and the empty line that ends it
+func headerBlockLength(node *MIMENode) int {
+	length := 2
+	for _, line := range node.Header {
+		length += len(line) + 2
+	}
+	return length
+}
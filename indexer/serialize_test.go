@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{
+			name:  "simple message",
+			email: "From: sender@example.com\r\nSubject: Test\r\n\r\nHello\r\nWorld\r\n",
+		},
+		{
+			name:  "folded headers",
+			email: "Subject: a long\r\n subject line\r\nTo: a@example.com,\r\n\tb@example.com\r\n\r\nHello",
+		},
+		{
+			name: "preamble and epilogue",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\nThis is a multi-part message\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nFirst\r\n" +
+				"--b\r\nContent-Type: text/html\r\n\r\n<p>Second</p>\r\n\r\n" +
+				"--b--\r\nEpilogue\r\n",
+		},
+		{
+			name: "nested multipart",
+			email: "Content-Type: multipart/mixed; boundary=a\r\n\r\n" +
+				"--a\r\nContent-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\n\r\nPlain\r\n--b\r\nContent-Type: text/html\r\n\r\n<b>HTML</b>\r\n--b--\r\n\r\n" +
+				"--a\r\nContent-Type: application/octet-stream\r\nContent-Transfer-Encoding: base64\r\n\r\nAAEC\r\n" +
+				"--a--",
+		},
+		{
+			name: "embedded message",
+			email: "Content-Type: multipart/mixed; boundary=a\r\n\r\n" +
+				"--a\r\nContent-Type: message/rfc822\r\n\r\nSubject: Inner\r\n\r\nInner body\r\n" +
+				"--a--\r\n",
+		},
+		{
+			name:  "header only",
+			email: "From: sender@example.com\r\nSubject: Test\r\n",
+		},
+		{
+			name:  "header without line break",
+			email: "Subject: Test",
+		},
+		{
+			name:  "empty body line",
+			email: "Subject: Test\r\n\r\n",
+		},
+		{
+			name: "transport padding",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b \t\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+				"--b--  \r\n",
+		},
+		{
+			name: "empty preamble and part bodies",
+			email: "Content-Type: multipart/mixed; boundary=b\r\n\r\n\r\n" +
+				"--b\r\n\r\n\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\n" +
+				"--b--",
+		},
+		{
+			name: "missing end boundary",
+			email: "Content-Type: multipart/mixed; boundary=a\r\n\r\n" +
+				"--a\r\nContent-Type: multipart/alternative; boundary=b\r\n\r\n--b\r\n\r\nFirst\r\n" +
+				"--a\r\n\r\nSecond\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			if serialized := string(Serialize(tree)); serialized != tt.email {
+				t.Errorf("Expected %q, got %q", tt.email, serialized)
+			}
+		})
+	}
+}
+
+func TestSerializeNormalizesLineBreaks(t *testing.T) {
+	email := "Content-Type: multipart/mixed; boundary=b\n\n--b\n\nHello\n--b--\n"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	expected := strings.ReplaceAll(email, "\n", "\r\n")
+	if serialized := string(Serialize(tree)); serialized != expected {
+		t.Errorf("Expected %q, got %q", expected, serialized)
+	}
+}
+
+func TestSerializeMultipartFields(t *testing.T) {
+	email := "Content-Type: multipart/mixed; boundary=b\r\n\r\nPreamble\r\n--b\r\n\r\nHello\r\n--b--\r\nEpilogue"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if string(tree.Body) != "Preamble" {
+		t.Errorf("Expected preamble %q, got %q", "Preamble", tree.Body)
+	}
+	if string(tree.Epilogue) != "\r\nEpilogue" {
+		t.Errorf("Expected epilogue %q, got %q", "\r\nEpilogue", tree.Epilogue)
+	}
+	if tree.Unterminated {
+		t.Errorf("Expected terminated multipart")
+	}
+}
@@ -0,0 +1,289 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// ExtractText returns the plain text content of a MIME tree. Inline text/plain
+// parts are preferred, text/html parts are converted when no plain version exists
+func ExtractText(tree *MIMENode) string {
+	return strings.TrimSpace(textFromNode(tree))
+}
+
+// textFromNode collects readable text from a node and its children
+func textFromNode(node *MIMENode) string {
+	if node == nil {
+		return ""
+	}
+
+	contentType := contentTypeOf(node)
+
+	// winmail.dat is sent as an attachment but carries the Outlook message body
+	if isTNEF(contentType) {
+		return tnefText(node)
+	}
+	if isAttachment(node) {
+		return ""
+	}
+
+	switch contentType.Type {
+	case "multipart":
+		// Encrypted payloads have no readable text, only the client can decrypt them
+		if strings.EqualFold(node.Multipart, "encrypted") {
+			return ""
+		}
+
+		if strings.EqualFold(node.Multipart, "alternative") {
+			for _, child := range node.ChildNodes {
+				if ct := contentTypeOf(child); ct.Type == "text" && strings.EqualFold(ct.Subtype, "plain") {
+					if text := textFromNode(child); text != "" {
+						return text
+					}
+				}
+			}
+			for _, child := range node.ChildNodes {
+				if text := textFromNode(child); text != "" {
+					return text
+				}
+			}
+			return ""
+		}
+
+		parts := make([]string, 0, len(node.ChildNodes))
+		for _, child := range node.ChildNodes {
+			if text := textFromNode(child); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+
+	case "text":
+		switch strings.ToLower(contentType.Subtype) {
+		case "plain":
+			text := nodeText(node)
+			if pgpArmorType(text) == "MESSAGE" {
+				return ""
+			}
+			return text
+		case "html":
+			return htmlToText(nodeText(node))
+		}
+	}
+
+	return ""
+}
+
+// tnefText returns the message body of a TNEF part, the plain text body is
+// preferred over the HTML and RTF bodies
+func tnefText(node *MIMENode) string {
+	data, err := DecodeBody(node)
+	if err != nil {
+		return ""
+	}
+	message, err := DecodeTNEF(data)
+	if err != nil {
+		return ""
+	}
+
+	if body := strings.TrimSpace(strings.ReplaceAll(message.Body, "\r\n", "\n")); body != "" {
+		return body
+	}
+	if message.BodyHTML != "" {
+		return htmlToText(message.BodyHTML)
+	}
+	return rtfToText(message.BodyRTF)
+}
+
+// nodeText returns the decoded body of a node with normalized line endings
+func nodeText(node *MIMENode) string {
+	body, err := DecodeBody(node)
+	if err != nil {
+		body = node.Body
+	}
+
+	contentType := contentTypeOf(node)
+	text := decodeCharset(body, contentType.Params["charset"])
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	if strings.EqualFold(contentType.Subtype, "plain") && strings.EqualFold(contentType.Params["format"], "flowed") {
+		text = unwrapFlowed(text, strings.EqualFold(contentType.Params["delsp"], "yes"))
+	}
+
+	return text
+}
+
+// unwrapFlowed joins soft line breaks of format=flowed text (RFC 3676). Quote
+// depth is preserved and lines are only joined within the same depth
+func unwrapFlowed(text string, delSp bool) string {
+	lines := strings.Split(text, "\n")
+	result := make([]string, 0, len(lines))
+
+	var paragraph strings.Builder
+	depth := -1
+
+	flush := func() {
+		if depth < 0 {
+			return
+		}
+		prefix := ""
+		if depth > 0 {
+			prefix = strings.Repeat(">", depth) + " "
+		}
+		result = append(result, prefix+paragraph.String())
+		paragraph.Reset()
+		depth = -1
+	}
+
+	for _, line := range lines {
+		lineDepth := 0
+		for lineDepth < len(line) && line[lineDepth] == '>' {
+			lineDepth++
+		}
+
+		content := line[lineDepth:]
+		// Remove space-stuffing
+		content = strings.TrimPrefix(content, " ")
+
+		// A change in quote depth ends the paragraph even after a soft break
+		if depth >= 0 && depth != lineDepth {
+			flush()
+		}
+
+		flowed := strings.HasSuffix(content, " ") && content != "-- "
+		if flowed && delSp {
+			content = content[:len(content)-1]
+		}
+
+		paragraph.WriteString(content)
+		depth = lineDepth
+
+		if !flowed {
+			flush()
+		}
+	}
+	flush()
+
+	return strings.Join(result, "\n")
+}
+
+// contentTypeOf returns the parsed Content-Type of a node, defaulting to text/plain
+func contentTypeOf(node *MIMENode) *ValueParams {
+	if ct, ok := node.ParsedHeader["content-type"].(*ValueParams); ok {
+		return ct
+	}
+
+	return &ValueParams{
+		Type:    "text",
+		Subtype: "plain",
+		Value:   "text/plain",
+		Params:  make(map[string]string),
+	}
+}
+
+// transferEncodingOf returns the lower case Content-Transfer-Encoding of a node
+func transferEncodingOf(node *MIMENode) string {
+	if cte, ok := node.ParsedHeader["content-transfer-encoding"].(string); ok {
+		return strings.ToLower(strings.TrimSpace(cte))
+	}
+	return "7bit"
+}
+
+// isAttachment checks if a node is explicitly marked as an attachment
+func isAttachment(node *MIMENode) bool {
+	if disposition, ok := node.ParsedHeader["content-disposition"].(*ValueParams); ok {
+		return strings.EqualFold(disposition.Value, "attachment")
+	}
+	return false
+}
+
+// DecodeBody returns the body of a node with its Content-Transfer-Encoding reverted
+func DecodeBody(node *MIMENode) ([]byte, error) {
+	return decodeTransferEncoding(node.Body, transferEncodingOf(node))
+}
+
+// decodeTransferEncoding reverts base64 and quoted-printable transfer encodings
+func decodeTransferEncoding(body []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		cleaned := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, string(body))
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(cleaned, "="))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+	default:
+		return body, nil
+	}
+}
+
+// decodeCharset converts single byte charsets to UTF-8. Input in other charsets
+// is returned as is, with invalid UTF-8 sequences replaced
+func decodeCharset(body []byte, charset string) string {
+	table, ok := charsetTable(charset)
+	if !ok {
+		return strings.ToValidUTF8(string(body), "\uFFFD")
+	}
+
+	runes := make([]rune, len(body))
+	for i, b := range body {
+		if r, ok := table[b]; ok {
+			runes[i] = r
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+	return string(runes)
+}
+
+// charsetTable returns the bytes of a single byte charset that differ from
+// Latin-1, ok is false for charsets decodeCharset does not convert
+func charsetTable(charset string) (table map[byte]rune, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "latin1", "iso_8859-1", "l1":
+		return nil, true
+	case "windows-1252", "cp1252", "x-cp1252":
+		return windows1252, true
+	case "iso-8859-15", "iso_8859-15", "latin-9", "latin9", "l9":
+		return iso885915, true
+	}
+	return nil, false
+}
+
+// headerWordDecoder decodes RFC 2047 encoded-words, including the single byte
+// charsets decodeCharset knows
+var headerWordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		if _, ok := charsetTable(charset); !ok {
+			return nil, fmt.Errorf("unsupported charset %s", charset)
+		}
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(decodeCharset(data, charset)), nil
+	},
+}
+
+// windows1252 lists the bytes of Windows-1252 that differ from Latin-1,
+// unassigned bytes keep their Latin-1 meaning
+var windows1252 = map[byte]rune{
+	0x80: '\u20AC', 0x82: '\u201A', 0x83: '\u0192', 0x84: '\u201E', 0x85: '\u2026', 0x86: '\u2020', 0x87: '\u2021',
+	0x88: '\u02C6', 0x89: '\u2030', 0x8A: '\u0160', 0x8B: '\u2039', 0x8C: '\u0152', 0x8E: '\u017D',
+	0x91: '\u2018', 0x92: '\u2019', 0x93: '\u201C', 0x94: '\u201D', 0x95: '\u2022', 0x96: '\u2013', 0x97: '\u2014',
+	0x98: '\u02DC', 0x99: '\u2122', 0x9A: '\u0161', 0x9B: '\u203A', 0x9C: '\u0153', 0x9E: '\u017E', 0x9F: '\u0178',
+}
+
+// iso885915 lists the bytes of ISO-8859-15 that differ from Latin-1
+var iso885915 = map[byte]rune{
+	0xA4: '\u20AC', 0xA6: '\u0160', 0xA8: '\u0161', 0xB4: '\u017D',
+	0xB8: '\u017E', 0xBC: '\u0152', 0xBD: '\u0153', 0xBE: '\u0178',
+}
@@ -0,0 +1,168 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestExtractTextBase64(t *testing.T) {
+	email := `From: sender@example.com
+Subject: Encoded
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: base64
+
+SGVsbG8gV29ybGQh
+Cg==`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if text := ExtractText(tree); text != "Hello World!" {
+		t.Errorf("Expected 'Hello World!', got %q", text)
+	}
+}
+
+func TestExtractTextLatin1(t *testing.T) {
+	email := "From: sender@example.com\nSubject: Latin1\nContent-Type: text/plain; charset=iso-8859-1\nContent-Transfer-Encoding: quoted-printable\n\nCaf=E9"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if text := ExtractText(tree); text != "Café" {
+		t.Errorf("Expected 'Café', got %q", text)
+	}
+}
+
+func TestExtractTextMixedCaseTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{
+			name:     "html",
+			email:    "Content-Type: Text/HTML\r\n\r\n<p>Hello</p>",
+			expected: "Hello",
+		},
+		{
+			name: "alternative",
+			email: "Content-Type: multipart/Alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/HTML\r\n\r\n<p>H</p>\r\n" +
+				"--b\r\nContent-Type: text/Plain\r\n\r\nPlain\r\n--b--\r\n",
+			expected: "Plain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ParseMIME([]byte(tt.email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			if text := ExtractText(tree); text != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, text)
+			}
+			if attachments := GetAttachments(tree); len(attachments) != 0 {
+				t.Errorf("Expected no attachments, got %d", len(attachments))
+			}
+		})
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name     string
+		charset  string
+		input    string
+		expected string
+	}{
+		{name: "latin1", charset: "ISO-8859-1", input: "caf\xe9", expected: "café"},
+		{name: "windows-1252", charset: "windows-1252", input: "caf\xe9 \x80 \x93quoted\x94", expected: "café € “quoted”"},
+		{name: "iso-8859-15", charset: "iso-8859-15", input: "\xa4 \xbd", expected: "€ œ"},
+		{name: "utf-8", charset: "utf-8", input: "café", expected: "café"},
+		{name: "unsupported charset", charset: "koi8-r", input: "caf\xe9", expected: "caf\uFFFD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := decodeCharset([]byte(tt.input), tt.charset); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractTextWindows1252(t *testing.T) {
+	email := "From: sender@example.com\nSubject: Windows\nContent-Type: text/plain; charset=windows-1252\n\ncaf\xe9"
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	if text := ExtractText(tree); text != "café" {
+		t.Errorf("Expected 'café', got %q", text)
+	}
+}
+
+func TestUnwrapFlowed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		delSp    bool
+		expected string
+	}{
+		{
+			name:     "soft line breaks",
+			input:    "This is a long \nparagraph that \nwas wrapped.\nNew line.",
+			expected: "This is a long paragraph that was wrapped.\nNew line.",
+		},
+		{
+			name:     "delsp removes the wrapping space",
+			input:    "Donaudampf \nschiff",
+			delSp:    true,
+			expected: "Donaudampfschiff",
+		},
+		{
+			name:     "quote depth preserved",
+			input:    "> Quoted text \n> continues here\n>> Deeper \n>> quote\nReply",
+			expected: "> Quoted text continues here\n>> Deeper quote\nReply",
+		},
+		{
+			name:     "depth change ends paragraph",
+			input:    "> Quoted \nNot quoted",
+			expected: "> Quoted \nNot quoted",
+		},
+		{
+			name:     "space stuffing and signature",
+			input:    "  indented\n-- \nSignature",
+			expected: " indented\n-- \nSignature",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := unwrapFlowed(tc.input, tc.delSp); result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractTextFormatFlowed(t *testing.T) {
+	email := "From: sender@example.com\nSubject: Flowed\nContent-Type: text/plain; charset=utf-8; format=flowed; delsp=yes\n\nThis paragraph was wrapped by the se \nnding client.\n\nSecond paragraph."
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	expected := "This paragraph was wrapped by the sending client.\n\nSecond paragraph."
+	if text := ExtractText(tree); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
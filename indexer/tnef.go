@@ -0,0 +1,505 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const tnefSignature = 0x223E9F78
+
+// TNEF attribute IDs (lower 16 bits of the attribute tag)
+const (
+	tnefAttBody           = 0x800C
+	tnefAttAttachData     = 0x800F
+	tnefAttAttachTitle    = 0x8010
+	tnefAttAttachRendData = 0x9002
+	tnefAttMAPIProps      = 0x9003
+	tnefAttAttachment     = 0x9005
+)
+
+// MAPI property IDs used when decoding TNEF
+const (
+	mapiBody             = 0x1000
+	mapiRTFCompressed    = 0x1009
+	mapiBodyHTML         = 0x1013
+	mapiAttachDataObj    = 0x3701
+	mapiAttachLongName   = 0x3707
+	mapiAttachMimeTag    = 0x370E
+	mapiAttachContentID  = 0x3712
+	mapiTypeMultiValue   = 0x1000
+	mapiTypeString8      = 0x001E
+	mapiTypeUnicode      = 0x001F
+	mapiTypeBinary       = 0x0102
+	mapiTypeObject       = 0x000D
+	mapiNamedPropertyMin = 0x8000
+)
+
+// TNEFMessage contains the content decoded from an application/ms-tnef part (winmail.dat)
+type TNEFMessage struct {
+	Body        string            `json:"body,omitempty"`
+	BodyHTML    string            `json:"bodyHtml,omitempty"`
+	BodyRTF     []byte            `json:"-"` // Decompressed RTF body
+	Attachments []*TNEFAttachment `json:"attachments,omitempty"`
+}
+
+// TNEFAttachment is a file embedded in a TNEF stream
+type TNEFAttachment struct {
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	ContentID   string `json:"contentId,omitempty"`
+	Data        []byte `json:"-"`
+}
+
+// mapiProperty is a decoded MAPI property, only the first value of
+// multi-valued properties is kept
+type mapiProperty struct {
+	id       uint16
+	propType uint16
+	data     []byte
+}
+
+// DecodeTNEF decodes a TNEF stream and returns its body and attachments
+func DecodeTNEF(data []byte) (*TNEFMessage, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint32(data) != tnefSignature {
+		return nil, fmt.Errorf("invalid TNEF signature")
+	}
+
+	message := &TNEFMessage{}
+	var current *TNEFAttachment
+
+	// Skip signature and legacy key
+	pos := 6
+	for pos < len(data) {
+		if pos+9 > len(data) {
+			return nil, fmt.Errorf("truncated TNEF attribute at offset %d", pos)
+		}
+
+		level := data[pos]
+		id := binary.LittleEndian.Uint32(data[pos+1:]) & 0xFFFF
+		length := int(binary.LittleEndian.Uint32(data[pos+5:]))
+		pos += 9
+
+		if length < 0 || pos+length+2 > len(data) {
+			return nil, fmt.Errorf("truncated TNEF attribute %#x", id)
+		}
+		value := data[pos : pos+length]
+		pos += length + 2 // attribute value and checksum
+
+		switch id {
+		case tnefAttBody:
+			message.Body = strings.TrimRight(decodeCharset(value, "windows-1252"), "\x00")
+
+		case tnefAttAttachRendData:
+			// Starts a new attachment, all following attachment attributes belong to it
+			current = &TNEFAttachment{}
+			message.Attachments = append(message.Attachments, current)
+
+		case tnefAttAttachTitle:
+			if current != nil && current.Filename == "" {
+				current.Filename = strings.TrimRight(decodeCharset(value, "windows-1252"), "\x00")
+			}
+
+		case tnefAttAttachData:
+			if current != nil {
+				current.Data = value
+			}
+
+		case tnefAttAttachment, tnefAttMAPIProps:
+			properties, err := decodeMAPIProperties(value)
+			if err != nil {
+				return nil, err
+			}
+			if level == 2 && current != nil {
+				applyAttachmentProperties(current, properties)
+			} else {
+				applyMessageProperties(message, properties)
+			}
+		}
+	}
+
+	return message, nil
+}
+
+// applyAttachmentProperties copies relevant MAPI properties to an attachment
+func applyAttachmentProperties(attachment *TNEFAttachment, properties []*mapiProperty) {
+	for _, prop := range properties {
+		switch prop.id {
+		case mapiAttachLongName:
+			if name := mapiString(prop); name != "" {
+				attachment.Filename = name
+			}
+		case mapiAttachMimeTag:
+			attachment.ContentType = strings.ToLower(mapiString(prop))
+		case mapiAttachContentID:
+			attachment.ContentID = mapiString(prop)
+		case mapiAttachDataObj:
+			if len(attachment.Data) == 0 && prop.propType == mapiTypeBinary {
+				attachment.Data = prop.data
+			}
+		}
+	}
+}
+
+// applyMessageProperties copies the message bodies from MAPI properties
+func applyMessageProperties(message *TNEFMessage, properties []*mapiProperty) {
+	for _, prop := range properties {
+		switch prop.id {
+		case mapiBody:
+			if message.Body == "" {
+				message.Body = mapiString(prop)
+			}
+		case mapiBodyHTML:
+			message.BodyHTML = mapiString(prop)
+		case mapiRTFCompressed:
+			if rtf, err := decompressRTF(prop.data); err == nil {
+				message.BodyRTF = rtf
+			}
+		}
+	}
+}
+
+// decodeMAPIProperties decodes an encoded MAPI property list
+func decodeMAPIProperties(data []byte) ([]*mapiProperty, error) {
+	r := &tnefReader{data: data}
+
+	count := r.uint32()
+	properties := make([]*mapiProperty, 0)
+
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		prop := &mapiProperty{
+			propType: r.uint16(),
+			id:       r.uint16(),
+		}
+
+		if prop.id >= mapiNamedPropertyMin {
+			// Named property: GUID, kind and either a numeric ID or a name
+			r.skip(16)
+			if r.uint32() == 0 {
+				r.skip(4)
+			} else {
+				r.skip(padTo4(int(r.uint32())))
+			}
+		}
+
+		baseType := prop.propType &^ mapiTypeMultiValue
+		values := uint32(1)
+		if prop.propType&mapiTypeMultiValue != 0 || isVariableMAPIType(baseType) {
+			values = r.uint32()
+		}
+
+		for v := uint32(0); v < values && r.err == nil; v++ {
+			var value []byte
+			if isVariableMAPIType(baseType) {
+				length := int(r.uint32())
+				value = r.bytes(length)
+				r.skip(padTo4(length) - length)
+			} else {
+				value = r.bytes(fixedMAPITypeSize(baseType))
+			}
+			if v == 0 {
+				prop.data = value
+			}
+		}
+
+		properties = append(properties, prop)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return properties, nil
+}
+
+// isVariableMAPIType checks if values of a MAPI type are length prefixed
+func isVariableMAPIType(propType uint16) bool {
+	switch propType {
+	case mapiTypeString8, mapiTypeUnicode, mapiTypeBinary, mapiTypeObject:
+		return true
+	}
+	return false
+}
+
+// fixedMAPITypeSize returns the encoded size of a fixed length MAPI type
+func fixedMAPITypeSize(propType uint16) int {
+	switch propType {
+	case 0x0005, 0x0006, 0x0007, 0x0014, 0x0040: // double, currency, apptime, int64, systime
+		return 8
+	case 0x0048: // CLSID
+		return 16
+	default: // short, long, float, error, boolean are padded to 4 bytes
+		return 4
+	}
+}
+
+// mapiString converts a string MAPI property to a Go string
+func mapiString(prop *mapiProperty) string {
+	if prop.propType&^mapiTypeMultiValue == mapiTypeUnicode {
+		u := make([]uint16, len(prop.data)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(prop.data[i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+	}
+	if prop.propType&^mapiTypeMultiValue == mapiTypeString8 {
+		// 8-bit strings use the ANSI codepage of the sender
+		return strings.TrimRight(decodeCharset(prop.data, "windows-1252"), "\x00")
+	}
+	return strings.TrimRight(strings.ToValidUTF8(string(prop.data), "\uFFFD"), "\x00")
+}
+
+func padTo4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// tnefReader reads little endian values and remembers the first error
+type tnefReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *tnefReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("truncated MAPI property data")
+		return nil
+	}
+	value := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return value
+}
+
+func (r *tnefReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *tnefReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *tnefReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// rtfPrebuf is the initial dictionary content of compressed RTF (MS-OXRTFCP)
+const rtfPrebuf = "{\\rtf1\\ansi\\mac\\deff0\\deftab720{\\fonttbl;}{\\f0\\fnil \\froman \\fswiss \\fmodern \\fscript \\fdecor MS Sans SerifSymbolArialTimes New RomanCourier{\\colortbl\\red0\\green0\\blue0\r\n\\par \\pard\\plain\\f0\\fs20\\b\\i\\u\\tab\\tx"
+
+// decompressRTF decompresses a PR_RTF_COMPRESSED value
+func decompressRTF(data []byte) ([]byte, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("compressed RTF header too short")
+	}
+
+	rawSize := int(binary.LittleEndian.Uint32(data[4:]))
+	compType := binary.LittleEndian.Uint32(data[8:])
+	input := data[16:]
+
+	switch compType {
+	case 0x414C454D: // "MELA", stored uncompressed
+		if rawSize > len(input) {
+			rawSize = len(input)
+		}
+		return input[:rawSize], nil
+	case 0x75465A4C: // "LZFu"
+	default:
+		return nil, fmt.Errorf("unknown compressed RTF type %#x", compType)
+	}
+
+	var dictionary [4096]byte
+	copy(dictionary[:], rtfPrebuf)
+	writePos := len(rtfPrebuf)
+
+	var out bytes.Buffer
+	pos := 0
+	for pos < len(input) {
+		control := input[pos]
+		pos++
+
+		for bit := 0; bit < 8 && pos < len(input); bit++ {
+			if control&(1<<bit) == 0 {
+				b := input[pos]
+				pos++
+				out.WriteByte(b)
+				dictionary[writePos] = b
+				writePos = (writePos + 1) % len(dictionary)
+				continue
+			}
+
+			if pos+2 > len(input) {
+				return out.Bytes(), nil
+			}
+			reference := int(input[pos])<<8 | int(input[pos+1])
+			pos += 2
+
+			offset := reference >> 4
+			length := reference&0xF + 2
+			if offset == writePos {
+				return out.Bytes(), nil
+			}
+
+			for i := 0; i < length; i++ {
+				b := dictionary[(offset+i)%len(dictionary)]
+				out.WriteByte(b)
+				dictionary[writePos] = b
+				writePos = (writePos + 1) % len(dictionary)
+			}
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// rtfSkippedDestinations are RTF groups that contain no message text
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"header": true, "footer": true, "object": true, "themedata": true, "datastore": true,
+	"latentstyles": true, "listtable": true, "listoverridetable": true, "rsidtbl": true,
+	"generator": true, "xmlnstbl": true, "mmathPr": true, "colorschememapping": true,
+}
+
+// rtfCodepages maps \ansicpg values to the charsets decodeCharset knows, other
+// codepages are read as Windows-1252
+var rtfCodepages = map[int]string{
+	1252: "windows-1252", 28591: "iso-8859-1", 28605: "iso-8859-15",
+}
+
+// rtfToText extracts the plain text of an RTF document, as Outlook sends the
+// body of rich text messages only as compressed RTF
+func rtfToText(rtf []byte) string {
+	var sb strings.Builder
+	skip := []bool{false}
+	uc := []int{1}   // fallback characters per \u, set by \uc for the group
+	pendingSkip := 0 // fallback characters after \u
+	charset := "windows-1252"
+
+	for i := 0; i < len(rtf); i++ {
+		skipping := skip[len(skip)-1]
+		c := rtf[i]
+
+		switch c {
+		case '{':
+			skip = append(skip, skipping)
+			uc = append(uc, uc[len(uc)-1])
+			// {\* ... } destinations are optional and ignored by readers that do not know them
+			if i+2 < len(rtf) && rtf[i+1] == '\\' && rtf[i+2] == '*' {
+				skip[len(skip)-1] = true
+			}
+			continue
+		case '}':
+			if len(skip) > 1 {
+				skip = skip[:len(skip)-1]
+				uc = uc[:len(uc)-1]
+			}
+			continue
+		case '\r', '\n':
+			continue
+		case '\\':
+		default:
+			if pendingSkip > 0 {
+				pendingSkip--
+			} else if !skipping {
+				sb.WriteString(decodeCharset([]byte{c}, charset))
+			}
+			continue
+		}
+
+		// Control symbols and control words
+		if i+1 >= len(rtf) {
+			break
+		}
+		next := rtf[i+1]
+		if !isASCIILetter(next) {
+			i++
+			var text string
+			switch next {
+			case '\\', '{', '}':
+				text = string(next)
+			case '~':
+				text = " "
+			case '\'':
+				if i+2 < len(rtf) {
+					if b, err := strconv.ParseUint(string(rtf[i+1:i+3]), 16, 8); err == nil {
+						text = decodeCharset([]byte{byte(b)}, charset)
+					}
+					i += 2
+				}
+			case '\r', '\n':
+				text = "\n"
+			}
+			if pendingSkip > 0 && text != "" {
+				pendingSkip--
+			} else if !skipping {
+				sb.WriteString(text)
+			}
+			continue
+		}
+
+		start := i + 1
+		end := start
+		for end < len(rtf) && isASCIILetter(rtf[end]) {
+			end++
+		}
+		word := string(rtf[start:end])
+		paramStart := end
+		if end < len(rtf) && rtf[end] == '-' {
+			end++
+		}
+		for end < len(rtf) && rtf[end] >= '0' && rtf[end] <= '9' {
+			end++
+		}
+		param, hasParam := 0, end > paramStart
+		if hasParam {
+			param, _ = strconv.Atoi(string(rtf[paramStart:end]))
+		}
+		// A space delimiting the control word belongs to it
+		if end < len(rtf) && rtf[end] == ' ' {
+			end++
+		}
+		i = end - 1
+
+		if rtfSkippedDestinations[word] {
+			skip[len(skip)-1] = true
+			continue
+		}
+		if skipping {
+			continue
+		}
+
+		switch word {
+		case "ansicpg":
+			if cp, ok := rtfCodepages[param]; ok {
+				charset = cp
+			}
+		case "uc":
+			if hasParam && param >= 0 {
+				uc[len(uc)-1] = param
+			}
+		case "par", "line", "row":
+			sb.WriteString("\n")
+		case "tab", "cell":
+			sb.WriteString("\t")
+		case "u":
+			if hasParam {
+				if param < 0 {
+					param += 65536
+				}
+				sb.WriteRune(rune(param))
+				pendingSkip = uc[len(uc)-1]
+			}
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,266 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// tnefBuilder creates TNEF streams for tests
+type tnefBuilder struct {
+	buf bytes.Buffer
+}
+
+func newTNEFBuilder() *tnefBuilder {
+	b := &tnefBuilder{}
+	binary.Write(&b.buf, binary.LittleEndian, uint32(tnefSignature))
+	binary.Write(&b.buf, binary.LittleEndian, uint16(0x0001))
+	return b
+}
+
+func (b *tnefBuilder) attribute(level byte, tag uint32, data []byte) *tnefBuilder {
+	b.buf.WriteByte(level)
+	binary.Write(&b.buf, binary.LittleEndian, tag)
+	binary.Write(&b.buf, binary.LittleEndian, uint32(len(data)))
+	b.buf.Write(data)
+
+	var checksum uint16
+	for _, c := range data {
+		checksum += uint16(c)
+	}
+	binary.Write(&b.buf, binary.LittleEndian, checksum)
+	return b
+}
+
+// mapiUnicodeProps encodes a MAPI property list of PT_UNICODE values
+func mapiUnicodeProps(props map[uint16]string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(props)))
+	for id, value := range props {
+		binary.Write(&buf, binary.LittleEndian, uint16(mapiTypeUnicode))
+		binary.Write(&buf, binary.LittleEndian, id)
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+
+		encoded := utf16.Encode([]rune(value + "\x00"))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(encoded)*2))
+		binary.Write(&buf, binary.LittleEndian, encoded)
+		buf.Write(make([]byte, padTo4(len(encoded)*2)-len(encoded)*2))
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeTNEF(t *testing.T) {
+	data := newTNEFBuilder().
+		attribute(1, 0x0002800C, []byte("Message body\x00")).
+		attribute(2, 0x00069002, make([]byte, 14)).
+		attribute(2, 0x00018010, []byte("REPORT~1.PDF\x00")).
+		attribute(2, 0x0006800F, []byte("%PDF-1.4 content")).
+		attribute(2, 0x00069005, mapiUnicodeProps(map[uint16]string{
+			mapiAttachLongName: "Quarterly report.pdf",
+			mapiAttachMimeTag:  "application/pdf",
+		})).
+		attribute(2, 0x00069002, make([]byte, 14)).
+		attribute(2, 0x00018010, []byte("r\xe9sum\xe9.txt\x00")).
+		attribute(2, 0x0006800F, []byte("Some notes")).
+		buf.Bytes()
+
+	message, err := DecodeTNEF(data)
+	if err != nil {
+		t.Fatalf("Failed to decode TNEF: %v", err)
+	}
+
+	if message.Body != "Message body" {
+		t.Errorf("Expected body 'Message body', got %q", message.Body)
+	}
+	if len(message.Attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(message.Attachments))
+	}
+
+	report := message.Attachments[0]
+	if report.Filename != "Quarterly report.pdf" || report.ContentType != "application/pdf" || string(report.Data) != "%PDF-1.4 content" {
+		t.Errorf("Unexpected first attachment: %s %s %q", report.Filename, report.ContentType, report.Data)
+	}
+
+	notes := message.Attachments[1]
+	if notes.Filename != "résumé.txt" || string(notes.Data) != "Some notes" {
+		t.Errorf("Unexpected second attachment: %s %q", notes.Filename, notes.Data)
+	}
+}
+
+func TestDecodeTNEFInvalid(t *testing.T) {
+	if _, err := DecodeTNEF([]byte("not tnef")); err == nil {
+		t.Error("Expected error for invalid signature")
+	}
+
+	truncated := newTNEFBuilder().attribute(1, 0x0002800C, []byte("body")).buf.Bytes()
+	if _, err := DecodeTNEF(truncated[:len(truncated)-4]); err == nil {
+		t.Error("Expected error for truncated stream")
+	}
+}
+
+func TestDecompressRTF(t *testing.T) {
+	if len(rtfPrebuf) != 207 {
+		t.Fatalf("Expected 207 byte RTF dictionary, got %d", len(rtfPrebuf))
+	}
+
+	// Example from MS-OXRTFCP section 3.1.1
+	compressed := []byte{
+		0x2d, 0x00, 0x00, 0x00, 0x2b, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xf1, 0xc5, 0xc7, 0xa7,
+		0x03, 0x00, 0x0a, 0x00, 0x72, 0x63, 0x70, 0x67, 0x31, 0x32, 0x35, 0x42, 0x32, 0x0a, 0xf3, 0x20,
+		0x68, 0x65, 0x6c, 0x09, 0x00, 0x20, 0x62, 0x77, 0x05, 0xb0, 0x6c, 0x64, 0x7d, 0x0a, 0x80, 0x0f,
+		0xa0,
+	}
+
+	rtf, err := decompressRTF(compressed)
+	if err != nil {
+		t.Fatalf("Failed to decompress RTF: %v", err)
+	}
+
+	expected := "{\\rtf1\\ansi\\ansicpg1252\\pard hello world}\r\n"
+	if string(rtf) != expected {
+		t.Errorf("Expected %q, got %q", expected, rtf)
+	}
+}
+
+func TestGetAttachmentsExpandsTNEF(t *testing.T) {
+	data := newTNEFBuilder().
+		attribute(2, 0x00069002, make([]byte, 14)).
+		attribute(2, 0x00018010, []byte("image.png\x00")).
+		attribute(2, 0x0006800F, []byte("PNG data")).
+		buf.Bytes()
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	email := `From: sender@example.com
+Subject: Outlook
+Content-Type: multipart/mixed; boundary="outlook"
+
+--outlook
+Content-Type: text/plain
+
+See attached.
+
+--outlook
+Content-Type: application/ms-tnef; name="winmail.dat"
+Content-Disposition: attachment; filename="winmail.dat"
+Content-Transfer-Encoding: base64
+
+` + encoded + `
+
+--outlook--`
+
+	tree, err := ParseMIME([]byte(email))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+
+	attachments := GetAttachments(tree)
+	if len(attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(attachments))
+	}
+
+	// The container stays, it is needed to rebuild the message
+	container := attachments[0]
+	if container.Filename != "winmail.dat" || container.TransferEncoding != "base64" || container.Container != "" {
+		t.Errorf("Expected winmail.dat container, got %+v", container)
+	}
+
+	image := attachments[1]
+	if image.Filename != "image.png" || string(image.Content) != "PNG data" {
+		t.Errorf("Unexpected attachment: %s %q", image.Filename, image.Content)
+	}
+	if image.Container != "application/ms-tnef" || image.Node != container.Node {
+		t.Errorf("Expected attachment extracted from TNEF container, got %+v", image)
+	}
+	if image.TransferEncoding != "binary" || image.EncodedSize != len("PNG data") {
+		t.Errorf("Expected binary encoding for extracted content, got %s with size %d", image.TransferEncoding, image.EncodedSize)
+	}
+}
+
+func TestExtractTextFromTNEF(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{
+			name:     "plain text body",
+			data:     newTNEFBuilder().attribute(1, 0x0002800C, []byte("Outlook body\x00")).buf.Bytes(),
+			expected: "Outlook body",
+		},
+		{
+			name:     "codepage body",
+			data:     newTNEFBuilder().attribute(1, 0x0002800C, []byte("Caf\xe9 r\xe9union\x00")).buf.Bytes(),
+			expected: "Café réunion",
+		},
+		{
+			name: "html body",
+			data: newTNEFBuilder().attribute(1, 0x00069003, mapiUnicodeProps(map[uint16]string{
+				mapiBodyHTML: "<p>HTML <b>body</b></p>",
+			})).buf.Bytes(),
+			expected: "HTML body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: application/ms-tnef; name=\"winmail.dat\"\r\n" +
+				"Content-Disposition: attachment; filename=\"winmail.dat\"\r\n" +
+				"Content-Transfer-Encoding: base64\r\n\r\n" +
+				base64.StdEncoding.EncodeToString(tt.data) + "\r\n--b--\r\n"
+
+			tree, err := ParseMIME([]byte(email))
+			if err != nil {
+				t.Fatalf("Failed to parse email: %v", err)
+			}
+
+			if text := ExtractText(tree); text != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestRTFToText(t *testing.T) {
+	rtf := `{\rtf1\ansi\ansicpg1252{\fonttbl{\f0\fswiss Arial;}}{\colortbl;\red0\green0\blue0;}` +
+		`{\*\generator Riched20;}\pard\f0 Hello \b world\b0 !\par Caf\'e9 \u8364? 5\{x\}\par}`
+
+	expected := "Hello world!\nCafé € 5{x}"
+	if text := rtfToText([]byte(rtf)); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
+
+func TestRTFToTextCodepage(t *testing.T) {
+	tests := []struct {
+		name     string
+		rtf      string
+		expected string
+	}{
+		{
+			name:     "smart quotes",
+			rtf:      `{\rtf1\ansi\ansicpg1252 It\'92s \'93quoted\'94 \'80 na` + "\xef" + `ve}`,
+			expected: "It’s “quoted” € naïve",
+		},
+		{
+			name:     "latin-9",
+			rtf:      `{\rtf1\ansi\ansicpg28605 \'a4}`,
+			expected: "€",
+		},
+		{
+			name:     "unicode fallback count",
+			rtf:      `{\rtf1\ansi{\uc2 \u8220\'93\'93x}\u8221\'94y}`,
+			expected: "“x”y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text := rtfToText([]byte(tt.rtf)); text != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, text)
+			}
+		})
+	}
+}